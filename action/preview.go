@@ -0,0 +1,169 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+type previewAction struct {
+	dataSource domain.DataSource
+	converter  converter
+	console    *bytes.Buffer
+	limit      int
+	output     io.Writer
+}
+
+// NewPreview creates action printing first limit records of data source to output
+// before and after conversion. console should collect console output of converter.
+func NewPreview(dataSource domain.DataSource, limit int, output io.Writer) previewAction {
+	return previewAction{
+		dataSource: dataSource,
+		converter:  nil,
+		console:    nil,
+		limit:      limit,
+		output:     output,
+	}
+}
+
+func (p previewAction) WithConverter(converter converter, console *bytes.Buffer) previewAction {
+	p.converter = converter
+	p.console = console
+	return p
+}
+
+func (p previewAction) Do(ctx context.Context) error {
+	var (
+		readCount     int
+		filteredNums  []string
+		failedCount   int
+		previewWriter = &previewWriter{w: p.output}
+	)
+	for recordNum := 1; recordNum <= p.limit && ctx.Err() == nil; recordNum++ {
+		v, err := p.dataSource.GetData(ctx)
+		if errors.Is(err, domain.ErrNoData) {
+			break
+		}
+		recordErr := new(domain.RecordError)
+		if errors.As(err, &recordErr) {
+			readCount++
+			failedCount++
+			previewWriter.header(recordNum)
+			previewWriter.raw("input", recordErr.Data)
+			previewWriter.line("read error", err.Error())
+			continue
+		}
+		if err != nil {
+			return errors.WithMessage(err, "get data")
+		}
+		readCount++
+
+		previewWriter.header(recordNum)
+		previewWriter.value("input", v.Data)
+		isFiltered, isFailed := p.preview(previewWriter, v)
+		if isFiltered {
+			filteredNums = append(filteredNums, fmt.Sprint(recordNum))
+		}
+		if isFailed {
+			failedCount++
+		}
+
+		if v.Acknowledger != nil {
+			err = v.Acknowledger.Nack()
+			if err != nil {
+				return errors.WithMessage(err, "nack source data")
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintf(p.output, "=== summary ===\nread: %d\nfiltered: %d", readCount, len(filteredNums))
+	if len(filteredNums) > 0 {
+		_, _ = fmt.Fprintf(p.output, " (records %s)", strings.Join(filteredNums, ", "))
+	}
+	_, _ = fmt.Fprintf(p.output, "\nfailed: %d\n", failedCount)
+
+	return previewWriter.err
+}
+
+func (p previewAction) preview(w *previewWriter, payload *domain.Payload) (bool, bool) {
+	if p.converter == nil {
+		w.value("output", payload.Data)
+		return false, false
+	}
+
+	metadata := payload.Metadata
+	if metadata == nil {
+		metadata = domain.NewMetadata()
+	}
+	p.console.Reset()
+	result, err := p.converter.Convert(payload.Data, metadata)
+	if p.console.Len() > 0 {
+		w.line("console", strings.TrimSuffix(p.console.String(), "\n"))
+	}
+
+	switch {
+	case err != nil:
+		w.line("script error", err.Error())
+		return false, true
+	case result == nil:
+		w.line("output", "<filtered out: script returned nil>")
+		return true, false
+	default:
+		w.value("output", result)
+		if len(metadata.Headers) > 0 {
+			w.value("headers", metadata.Headers)
+		}
+		return false, false
+	}
+}
+
+type previewWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *previewWriter) header(recordNum int) {
+	p.printf("=== record %d ===\n", recordNum)
+}
+
+func (p *previewWriter) line(title string, text string) {
+	p.printf("%s:\n%s\n", title, text)
+}
+
+func (p *previewWriter) raw(title string, data []byte) {
+	p.line(title, string(data))
+}
+
+func (p *previewWriter) value(title string, v any) {
+	data, ok := v.([]byte)
+	if ok {
+		p.raw(title, data)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(v)
+	if err != nil {
+		p.line(title, fmt.Sprintf("<marshal error: %v>", err))
+		return
+	}
+	p.raw(title, bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+func (p *previewWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	_, err := fmt.Fprintf(p.w, format, args...)
+	if err != nil {
+		p.err = errors.WithMessage(err, "write preview")
+	}
+}
@@ -14,36 +14,74 @@ import (
 )
 
 const (
-	poolSize = 300
+	poolSize                = 300
+	checkpointFlushInterval = time.Second
 )
 
 type converter interface {
-	Convert(data any) (any, error)
+	Convert(data any, metadata *domain.Metadata) (any, error)
 }
 
 type publisher interface {
-	Publish(ctx context.Context, data any) error
+	Publish(ctx context.Context, data any, metadata *domain.Metadata) error
+}
+
+type rejectWriter interface {
+	Write(reject domain.Reject) error
+}
+
+type checkpointer interface {
+	Track(position domain.Position) func()
+	Flush() error
+}
+
+type metricStorage interface {
+	IncRead()
+	IncConverted()
+	IncFiltered()
+	IncPublished()
+	IncFailed(stage string)
+	ObserveScriptDuration(duration time.Duration)
+	ObservePublishDuration(duration time.Duration)
 }
 
 type publishAction struct {
-	dataSource domain.DataSource
-	converter  converter
-	target     publisher
+	dataSource   domain.DataSource
+	converter    converter
+	target       publisher
+	checkpointer checkpointer
+	rejectWriter rejectWriter
+	maxErrors    uint64
+	metrics      metricStorage
+
+	shutdownTimeout time.Duration
 
 	publishedCounter *atomic.Uint64
+	errorsCounter    *atomic.Uint64
 
 	logInterval time.Duration
 	logger      log.Logger
 }
 
-func NewPublish(dataSource domain.DataSource, target publisher) publishAction {
+func NewPublish(
+	dataSource domain.DataSource,
+	target publisher,
+	metrics metricStorage,
+	logger log.Logger,
+) publishAction {
 	return publishAction{
 		dataSource:       dataSource,
 		converter:        nil,
 		target:           target,
+		checkpointer:     nil,
+		rejectWriter:     nil,
+		maxErrors:        0,
+		metrics:          metrics,
+		shutdownTimeout:  0,
 		publishedCounter: new(atomic.Uint64),
+		errorsCounter:    new(atomic.Uint64),
 		logInterval:      0,
-		logger:           nil,
+		logger:           logger,
 	}
 }
 
@@ -52,22 +90,114 @@ func (p publishAction) WithConverter(converter converter) publishAction {
 	return p
 }
 
-func (p publishAction) LogProgress(logInterval time.Duration, logger log.Logger) publishAction {
+func (p publishAction) WithCheckpointer(checkpointer checkpointer) publishAction {
+	p.checkpointer = checkpointer
+	return p
+}
+
+func (p publishAction) WithRejects(rejectWriter rejectWriter, maxErrors uint64) publishAction {
+	p.rejectWriter = rejectWriter
+	p.maxErrors = maxErrors
+	return p
+}
+
+func (p publishAction) LogProgress(logInterval time.Duration) publishAction {
 	p.logInterval = logInterval
-	p.logger = logger
+	return p
+}
+
+// WithShutdownTimeout sets the time given to in-flight data to be published
+// after ctx is canceled and reading from data source is stopped.
+func (p publishAction) WithShutdownTimeout(shutdownTimeout time.Duration) publishAction {
+	p.shutdownTimeout = shutdownTimeout
 	return p
 }
 
 func (p publishAction) Do(ctx context.Context, shouldPublishSync bool) error {
+	publishCtx, cancelPublish := p.publishContext(ctx)
+	defer cancelPublish()
+
 	if p.logInterval > 0 {
 		done := make(chan struct{})
 		defer close(done)
-		go p.logProgress(ctx, done)
+		go p.logProgress(publishCtx, done)
+	}
+	var (
+		stopFlushing    = make(chan struct{})
+		flushingStopped = make(chan struct{})
+	)
+	if p.checkpointer != nil {
+		go p.flushCheckpoints(publishCtx, stopFlushing, flushingStopped)
 	}
+
+	var err error
 	if shouldPublishSync {
-		return p.doSync(ctx)
+		err = p.doSync(ctx, publishCtx)
+	} else {
+		err = p.doAsync(ctx, publishCtx)
+	}
+
+	if p.checkpointer != nil {
+		// periodic flush is stopped, so it does not overwrite the final state
+		close(stopFlushing)
+		<-flushingStopped
+		flushErr := p.checkpointer.Flush()
+		if flushErr != nil && err == nil {
+			err = errors.WithMessage(flushErr, "flush checkpoint")
+		}
+	}
+	p.logSummary(publishCtx, ctx.Err() != nil)
+
+	if err == nil && ctx.Err() != nil {
+		return errors.WithMessage(ctx.Err(), "publishing interrupted")
+	}
+	return err
+}
+
+// publishContext returns context for publishing read data,
+// which is canceled only after shutdownTimeout since ctx is done.
+func (p publishAction) publishContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	publishCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-publishCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+
+		p.logger.Info(publishCtx, "stop reading data, waiting for in-flight data to be published",
+			log.String("shutdownTimeout", p.shutdownTimeout.String()),
+		)
+		timer := time.NewTimer(p.shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-publishCtx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+	return publishCtx, cancel
+}
+
+func (p publishAction) flushCheckpoints(ctx context.Context, done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(checkpointFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		}
+
+		err := p.checkpointer.Flush()
+		if err != nil {
+			p.logger.Error(ctx, errors.WithMessage(err, "flush checkpoint"))
+		}
 	}
-	return p.doAsync(ctx)
 }
 
 const (
@@ -77,6 +207,7 @@ const (
 	totalPublishedLogField = "totalPublished"
 	intervalLogField       = "interval"
 	mpsLogField            = "mps"
+	totalRejectedLogField  = "totalRejected"
 )
 
 func (p publishAction) logProgress(ctx context.Context, done <-chan struct{}) {
@@ -111,6 +242,9 @@ func (p publishAction) logProgress(ctx context.Context, done <-chan struct{}) {
 		if progress.ReadDataPercent != nil {
 			logFields = append(logFields, log.String(doneReadingLogField, fmt.Sprintf("%0.2f%%", *progress.ReadDataPercent)))
 		}
+		if p.rejectWriter != nil {
+			logFields = append(logFields, log.Any(totalRejectedLogField, min(p.errorsCounter.Load(), p.maxErrors)))
+		}
 		p.logger.Info(ctx, "progress...", logFields...)
 
 		readDataCount = progress.ReadDataCount
@@ -118,7 +252,27 @@ func (p publishAction) logProgress(ctx context.Context, done <-chan struct{}) {
 	}
 }
 
-func (p publishAction) doAsync(ctx context.Context) error {
+func (p publishAction) logSummary(ctx context.Context, isInterrupted bool) {
+	progress := p.dataSource.Progress()
+	logFields := []log.Field{
+		log.Any(readLogField, progress.ReadDataCount),
+		log.Any(totalPublishedLogField, p.publishedCounter.Load()),
+	}
+	if progress.ReadDataPercent != nil {
+		logFields = append(logFields, log.String(doneReadingLogField, fmt.Sprintf("%0.2f%%", *progress.ReadDataPercent)))
+	}
+	if p.rejectWriter != nil {
+		logFields = append(logFields, log.Any(totalRejectedLogField, min(p.errorsCounter.Load(), p.maxErrors)))
+	}
+
+	if isInterrupted {
+		p.logger.Warn(ctx, "publishing interrupted", logFields...)
+		return
+	}
+	p.logger.Info(ctx, "publishing finished", logFields...)
+}
+
+func (p publishAction) doAsync(readCtx context.Context, ctx context.Context) error {
 	var (
 		wg      = new(sync.WaitGroup)
 		errChan = make(chan error, poolSize)
@@ -126,7 +280,8 @@ func (p publishAction) doAsync(ctx context.Context) error {
 
 	pool, err := ants.NewPoolWithFunc(poolSize, func(v any) {
 		defer wg.Done()
-		err := p.submit(ctx, v)
+		task, _ := v.(task)
+		err := p.submit(ctx, task)
 		if err != nil {
 			errChan <- errors.WithMessage(err, "submit")
 		}
@@ -136,9 +291,9 @@ func (p publishAction) doAsync(ctx context.Context) error {
 	}
 	defer pool.Release()
 
-	err = p.do(ctx, func(ctx context.Context, v any) error {
+	err = p.do(readCtx, ctx, func(ctx context.Context, task task) error {
 		wg.Add(1)
-		err = pool.Invoke(v)
+		err = pool.Invoke(task)
 		if err != nil {
 			return errors.WithMessage(err, "pool invoke")
 		}
@@ -170,51 +325,177 @@ func (p publishAction) doAsync(ctx context.Context) error {
 	}
 }
 
-func (p publishAction) doSync(ctx context.Context) error {
-	return p.do(ctx, p.submit)
+func (p publishAction) doSync(readCtx context.Context, ctx context.Context) error {
+	return p.do(readCtx, ctx, p.submit)
 }
 
-type submitFunc func(ctx context.Context, v any) error
+type task struct {
+	requestId    string
+	data         any
+	raw          []byte
+	metadata     *domain.Metadata
+	acknowledger domain.Acknowledger
+	commit       func()
+}
 
-func (p publishAction) do(ctx context.Context, submitFn submitFunc) error {
-	for {
-		v, err := p.dataSource.GetData(ctx)
+type submitFunc func(ctx context.Context, task task) error
+
+// do reads data with readCtx until it is canceled or data source is drained
+// and submits read data with ctx.
+func (p publishAction) do(readCtx context.Context, ctx context.Context, submitFn submitFunc) error {
+	for readCtx.Err() == nil {
+		v, err := p.dataSource.GetData(readCtx)
+		recordErr := new(domain.RecordError)
 		switch {
+		case readCtx.Err() != nil:
+			return nil
 		case errors.Is(err, domain.ErrNoData):
 			return nil
+		case errors.As(err, &recordErr):
+			p.metrics.IncRead()
+			err = p.rejectRecord(ctx, recordErr, err)
+			if err != nil {
+				return errors.WithMessage(err, "get data")
+			}
+			continue
 		case err != nil:
 			return errors.WithMessage(err, "get data")
 		}
+		p.metrics.IncRead()
 
 		if v.RequestId != "" {
 			ctx = log.ToContext(ctx, log.String("requestId", v.RequestId)) // nolint:fatcontext
 		}
-		err = submitFn(ctx, v.Data)
+		err = submitFn(ctx, task{
+			requestId:    v.RequestId,
+			data:         v.Data,
+			raw:          v.Raw,
+			metadata:     v.Metadata,
+			acknowledger: v.Acknowledger,
+			commit:       p.track(v.Position),
+		})
 		if err != nil {
 			return errors.WithMessage(err, "submit data")
 		}
 	}
+	return nil
+}
+
+func (p publishAction) track(position domain.Position) func() {
+	if p.checkpointer == nil {
+		return func() {}
+	}
+	return p.checkpointer.Track(position)
 }
 
-func (p publishAction) submit(ctx context.Context, v any) error {
-	var err error
+func (p publishAction) submit(ctx context.Context, task task) error {
+	err := p.process(ctx, task)
+	if task.acknowledger == nil {
+		return err
+	}
+
+	if err != nil {
+		nackErr := task.acknowledger.Nack()
+		if nackErr != nil {
+			return errors.WithMessagef(nackErr, "nack source data after error '%v'", err)
+		}
+		return err
+	}
+
+	err = task.acknowledger.Ack()
+	if err != nil {
+		return errors.WithMessage(err, "ack source data")
+	}
+	return nil
+}
+
+func (p publishAction) process(ctx context.Context, task task) error {
+	var (
+		v        = task.data
+		metadata = task.metadata
+		err      error
+	)
 	if p.converter != nil {
-		v, err = p.converter.Convert(v)
+		if metadata == nil {
+			metadata = domain.NewMetadata()
+		}
+		start := time.Now()
+		v, err = p.converter.Convert(v, metadata)
+		p.metrics.ObserveScriptDuration(time.Since(start))
 		if err != nil {
-			return errors.WithMessage(err, "convert data with script")
+			return p.reject(ctx, domain.ConvertStage, task, errors.WithMessage(err, "convert data with script"))
 		}
+		p.metrics.IncConverted()
 	}
 
 	if v == nil {
+		p.metrics.IncFiltered()
+		task.commit()
 		return nil
 	}
 
-	err = p.target.Publish(ctx, v)
+	start := time.Now()
+	err = p.target.Publish(ctx, v, metadata)
+	p.metrics.ObservePublishDuration(time.Since(start))
 	if err != nil {
-		return errors.WithMessage(err, "publish data to target")
+		return p.reject(ctx, domain.PublishStage, task, errors.WithMessage(err, "publish data to target"))
 	}
 
 	p.publishedCounter.Add(1)
+	p.metrics.IncPublished()
+	task.commit()
+
+	return nil
+}
+
+// rejectRecord rejects data which is failed to be read and acks it in data source if it is rejected.
+func (p publishAction) rejectRecord(ctx context.Context, recordErr *domain.RecordError, err error) error {
+	err = p.reject(ctx, domain.ReadStage, task{
+		data:   recordErr.Data,
+		raw:    recordErr.Data,
+		commit: p.track(recordErr.Position),
+	}, err)
+	if recordErr.Acknowledger == nil {
+		return err
+	}
+
+	if err != nil {
+		nackErr := recordErr.Acknowledger.Nack()
+		if nackErr != nil {
+			return errors.WithMessagef(nackErr, "nack source data after error '%v'", err)
+		}
+		return err
+	}
+	err = recordErr.Acknowledger.Ack()
+	if err != nil {
+		return errors.WithMessage(err, "ack source data")
+	}
+	return nil
+}
+
+func (p publishAction) reject(ctx context.Context, stage string, task task, err error) error {
+	p.metrics.IncFailed(stage)
+	if p.rejectWriter == nil || ctx.Err() != nil {
+		return err
+	}
+	if p.errorsCounter.Add(1) > p.maxErrors {
+		return errors.WithMessagef(err, "max errors count %d exceeded", p.maxErrors)
+	}
+
+	var data any = task.data
+	if task.raw != nil {
+		data = task.raw
+	}
+	writeErr := p.rejectWriter.Write(domain.Reject{
+		Stage:     stage,
+		Error:     err.Error(),
+		RequestId: task.requestId,
+		Data:      data,
+	})
+	if writeErr != nil {
+		return errors.WithMessagef(writeErr, "write reject of error '%v'", err)
+	}
+	task.commit()
 
 	return nil
 }
@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+type Tracker struct {
+	filePath string
+
+	flushLock    *sync.Mutex
+	lock         *sync.Mutex
+	state        domain.Checkpoint
+	nextSeq      uint64
+	committedSeq uint64
+	positions    map[uint64]domain.Position
+	doneSeqs     map[uint64]struct{}
+	isDirty      bool
+}
+
+func NewTracker(filePath string, state domain.Checkpoint) *Tracker {
+	return &Tracker{
+		filePath:     filePath,
+		flushLock:    new(sync.Mutex),
+		lock:         new(sync.Mutex),
+		state:        state,
+		nextSeq:      0,
+		committedSeq: 0,
+		positions:    make(map[uint64]domain.Position),
+		doneSeqs:     make(map[uint64]struct{}),
+		isDirty:      false,
+	}
+}
+
+func Load(filePath string) (domain.Checkpoint, error) {
+	bytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return domain.Checkpoint{}, errors.WithMessagef(err, "read file '%s'", filePath)
+	}
+	var state domain.Checkpoint
+	err = json.Unmarshal(bytes, &state)
+	if err != nil {
+		return domain.Checkpoint{}, errors.WithMessagef(err, "unmarshal checkpoint from file '%s'", filePath)
+	}
+	return state, nil
+}
+
+func (t *Tracker) Track(position domain.Position) func() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	seq := t.nextSeq
+	t.nextSeq++
+	t.positions[seq] = position
+
+	return func() { t.commit(seq) }
+}
+
+func (t *Tracker) commit(seq uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.doneSeqs[seq] = struct{}{}
+	for {
+		_, ok := t.doneSeqs[t.committedSeq]
+		if !ok {
+			return
+		}
+		position := t.positions[t.committedSeq]
+		if position != nil {
+			position.Apply(&t.state)
+			t.isDirty = true
+		}
+		delete(t.doneSeqs, t.committedSeq)
+		delete(t.positions, t.committedSeq)
+		t.committedSeq++
+	}
+}
+
+// Flush writes committed state to file if it has changed since the last flush.
+// Flushes are serialized, so an older state never overwrites a newer one,
+// and the state is flushed again by the next call if writing fails.
+func (t *Tracker) Flush() error {
+	t.flushLock.Lock()
+	defer t.flushLock.Unlock()
+
+	t.lock.Lock()
+	if !t.isDirty {
+		t.lock.Unlock()
+		return nil
+	}
+	bytes, err := json.Marshal(t.state)
+	t.isDirty = false
+	t.lock.Unlock()
+	if err != nil {
+		t.markDirty()
+		return errors.WithMessage(err, "marshal checkpoint")
+	}
+
+	err = t.write(bytes)
+	if err != nil {
+		t.markDirty()
+		return err
+	}
+	return nil
+}
+
+func (t *Tracker) markDirty() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.isDirty = true
+}
+
+func (t *Tracker) write(bytes []byte) error {
+	tmpFilePath := t.filePath + ".tmp"
+	err := os.WriteFile(tmpFilePath, bytes, 0o644) // nolint:mnd,gosec
+	if err != nil {
+		return errors.WithMessagef(err, "write file '%s'", tmpFilePath)
+	}
+	err = os.Rename(tmpFilePath, t.filePath)
+	if err != nil {
+		return errors.WithMessagef(err, "rename file '%s' to '%s'", tmpFilePath, t.filePath)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package checkpoint
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/txix-open/mqpusher/domain"
+)
+
+func TestTrackerCommitOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		positions   []domain.Position
+		commitOrder []int
+		// expected offsets of state after each commit
+		expected []int64
+	}{
+		{
+			name:        "in order",
+			positions:   []domain.Position{domain.OffsetPosition(10), domain.OffsetPosition(20), domain.OffsetPosition(30)},
+			commitOrder: []int{0, 1, 2},
+			expected:    []int64{10, 20, 30},
+		},
+		{
+			name:        "reverse order",
+			positions:   []domain.Position{domain.OffsetPosition(10), domain.OffsetPosition(20), domain.OffsetPosition(30)},
+			commitOrder: []int{2, 1, 0},
+			expected:    []int64{0, 0, 30},
+		},
+		{
+			name: "gap is filled later",
+			positions: []domain.Position{
+				domain.OffsetPosition(10), domain.OffsetPosition(20), domain.OffsetPosition(30), domain.OffsetPosition(40),
+			},
+			commitOrder: []int{0, 2, 3, 1},
+			expected:    []int64{10, 10, 10, 40},
+		},
+		{
+			name:        "nil position keeps previous state",
+			positions:   []domain.Position{domain.OffsetPosition(10), nil, domain.OffsetPosition(30)},
+			commitOrder: []int{1, 0, 2},
+			expected:    []int64{0, 10, 30},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := NewTracker("", domain.Checkpoint{})
+			commits := make([]func(), 0, len(test.positions))
+			for _, position := range test.positions {
+				commits = append(commits, tracker.Track(position))
+			}
+			for i, idx := range test.commitOrder {
+				commits[idx]()
+				if tracker.state.Offset != test.expected[i] {
+					t.Fatalf("after commit of %d: expected offset %d, got %d", idx, test.expected[i], tracker.state.Offset)
+				}
+			}
+			if len(tracker.positions) != 0 || len(tracker.doneSeqs) != 0 {
+				t.Fatalf("expected no pending positions, got %d positions and %d done seqs",
+					len(tracker.positions), len(tracker.doneSeqs))
+			}
+		})
+	}
+}
+
+func TestTrackerFlush(t *testing.T) {
+	t.Parallel()
+
+	filePath := filepath.Join(t.TempDir(), "checkpoint.json")
+	tracker := NewTracker(filePath, domain.Checkpoint{Source: "json"})
+
+	err := tracker.Flush()
+	if err != nil {
+		t.Fatalf("flush without changes: %v", err)
+	}
+	_, err = Load(filePath)
+	if err == nil {
+		t.Fatal("expected no file to be written without committed positions")
+	}
+
+	commit := tracker.Track(domain.OffsetPosition(20))
+	tracker.Track(domain.OffsetPosition(30))
+	commit()
+	err = tracker.Flush()
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	state, err := Load(filePath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if state.Source != "json" || state.Offset != 20 {
+		t.Fatalf("expected source 'json' and offset 20, got '%s' and %d", state.Source, state.Offset)
+	}
+}
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/mqpusher/action"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/script"
+	"github.com/urfave/cli/v3"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	countFlag = "count"
+)
+
+const (
+	defaultPreviewCount = 10
+	previewQueryAlias   = "__mqpusher_preview"
+)
+
+func Preview() *cli.Command {
+	return &cli.Command{
+		Name:  "preview",
+		Usage: "Print first records of data source before and after conversion script without publishing",
+		Flags: append(sourceFlags(),
+			&cli.UintFlag{
+				Name:    countFlag,
+				Aliases: []string{"n"},
+				Usage:   "Count of records to preview",
+				Value:   defaultPreviewCount,
+			},
+		),
+		Action: preview,
+	}
+}
+
+func preview(ctx context.Context, cmd *cli.Command) error {
+	sourceType := strings.ToLower(cmd.String(sourceFlag))
+	cfg, err := loadAndUpdateConfig(cmd, sourceType)
+	if err != nil {
+		return errors.WithMessage(err, "load and update config")
+	}
+
+	logLevel, err := zapcore.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return errors.WithMessage(err, "parse log level")
+	}
+	logger, err := log.New(log.WithLevel(logLevel))
+	if err != nil {
+		return errors.WithMessage(err, "new logger")
+	}
+
+	if sourceType == rmqSrc && cfg.DataSources.RabbitMq != nil {
+		// previewed messages are returned to the source queue
+		cfg.DataSources.RabbitMq.AckAfterPublish = true
+		cfg.DataSources.RabbitMq.RequeueOnError = true
+	}
+	if sourceType == dbSrc && cfg.DataSources.DataBase != nil {
+		updateDbPreviewCfg(cfg.DataSources.DataBase, cmd.Uint(countFlag))
+	}
+	dataSource, err := defineDataSource(ctx, sourceType, cfg, domain.Checkpoint{Source: sourceType}, logger)
+	if err != nil {
+		return errors.WithMessage(err, "define source")
+	}
+	defer func() {
+		err := dataSource.Close(context.WithoutCancel(ctx))
+		if err != nil {
+			logger.Error(ctx, errors.WithMessage(err, "close data source"))
+		}
+	}()
+
+	previewAction := action.NewPreview(dataSource, int(cmd.Uint(countFlag)), os.Stdout) // nolint:gosec
+	if cfg.ScriptPath != "" {
+		converter, err := script.NewConverter(cfg.ScriptPath)
+		if err != nil {
+			return errors.WithMessage(err, "new converter script")
+		}
+		console := new(bytes.Buffer)
+		previewAction = previewAction.WithConverter(converter.WithConsole(script.NewWriterConsole(console)), console)
+	}
+
+	err = previewAction.Do(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "do preview action")
+	}
+
+	return nil
+}
+
+// updateDbPreviewCfg replaces table or query of db data source with query selecting only previewed rows,
+// so preview does not create materialized view and does not read the whole table.
+func updateDbPreviewCfg(cfg *conf.DbDataSource, limit uint64) {
+	query := cfg.Query
+	if query == "" {
+		columns := "*"
+		if len(cfg.SelectedColumns) > 0 {
+			columns = strings.Join(cfg.SelectedColumns, ",")
+		}
+		query = fmt.Sprintf("SELECT %s FROM %s", columns, cfg.Table)
+		whereClause, _ := strings.CutPrefix(cfg.WhereClause, "WHERE ")
+		if whereClause != "" {
+			query += " WHERE " + whereClause
+		}
+	}
+
+	orderBy := ""
+	if len(cfg.PrimaryKey) > 0 {
+		orderBy = " ORDER BY " + strings.Join(cfg.PrimaryKey, ",")
+	}
+	cfg.Query = fmt.Sprintf("SELECT * FROM (%s) AS %s%s LIMIT %d", query, previewQueryAlias, orderBy, limit)
+	cfg.SelectedColumns = nil
+	cfg.WhereClause = ""
+	cfg.PrimaryKey = nil
+	cfg.PartitionKey = ""
+	cfg.Parallel = 1
+}
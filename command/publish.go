@@ -7,13 +7,19 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/metrics"
 	"github.com/txix-open/isp-kit/validator"
 	"github.com/txix-open/mqpusher/action"
+	"github.com/txix-open/mqpusher/checkpoint"
 	"github.com/txix-open/mqpusher/conf"
 	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/reject"
 	"github.com/txix-open/mqpusher/rmq"
 	"github.com/txix-open/mqpusher/script"
 	"github.com/txix-open/mqpusher/source"
+	"github.com/txix-open/mqpusher/stats"
+	"github.com/txix-open/mqpusher/target"
+	"github.com/txix-open/mqpusher/utils"
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap/zapcore"
 )
@@ -27,43 +33,61 @@ const (
 	scriptFlag      = "script"
 	syncFlag        = "sync"
 	plainTextFlag   = "plain-text"
+	checkpointFlag  = "checkpoint"
+	resumeFlag      = "resume"
+	maxErrorsFlag   = "max-errors"
+	rejectFileFlag  = "reject-file"
+	confirmFlag     = "confirm"
+	mandatoryFlag   = "mandatory"
+	queryParamFlag  = "query-param"
+	metricsAddrFlag = "metrics-addr"
+	jsonFormatFlag  = "json-format"
+	arrayPathFlag   = "array-path"
+	recordPathFlag  = "record-path"
+	maxRecordFlag   = "max-record-size"
+	recursiveFlag   = "recursive"
+	includeFlag     = "include"
+	excludeFlag     = "exclude"
+	sortOrderFlag   = "sort-order"
+	shutdownFlag    = "shutdown-timeout"
+	dryRunFlag      = "dry-run"
+	outputFlag      = "output"
+	outputFmtFlag   = "output-format"
+	sheetFlag       = "sheet"
+	sheetIndexFlag  = "sheet-index"
+	cellRangeFlag   = "range"
+	columnFlag      = "column"
 )
 
 const (
-	csvSrc  = "csv"
-	jsonSrc = "json"
-	dbSrc   = "db"
-	rmqSrc  = "rmq"
+	defaultRejectFilePath = "rejects.jsonl"
 )
 
-func Publish() *cli.Command {
+const (
+	jsonArrayFormat = "array"
+)
+
+const (
+	csvSrc      = "csv"
+	jsonSrc     = "json"
+	dbSrc       = "db"
+	rmqSrc      = "rmq"
+	xlsxSrc     = "xlsx"
+	parquetSrc  = "parquet"
+	pgCdcSrc    = "pg-cdc"
+	pgNotifySrc = "pg-notify"
+)
+
+// Publish returns publish command, which registers its metrics in reg.
+func Publish(reg *metrics.Registry) *cli.Command {
 	return &cli.Command{
 		Name:  "publish",
 		Usage: "Publish data to a single RabbitMQ queue",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:     sourceFlag,
-				Aliases:  []string{"s"},
-				Required: true,
-				Usage:    "Data source type (available: csv, json, db, rmq)",
-			},
-			&cli.StringFlag{
-				Name:    filePathFlag,
-				Aliases: []string{"f"},
-				Usage:   "Path to data source file (used for csv and json data sources)",
-			},
-			&cli.StringFlag{
-				Name:  scriptFlag,
-				Usage: "Path to file with data conversion script",
-			},
+		Flags: append(sourceFlags(),
 			&cli.DurationFlag{
 				Name:  logIntervalFlag,
 				Usage: "Progress logging interval",
 			},
-			&cli.StringFlag{
-				Name:  csvSepFlag,
-				Usage: "Custom csv separator",
-			},
 			&cli.BoolFlag{
 				Name:    logMsgFlag,
 				Aliases: []string{"l"},
@@ -75,17 +99,145 @@ func Publish() *cli.Command {
 				Usage: "Enable synchronous publication of data to the target queue",
 				Value: false,
 			},
+			&cli.BoolFlag{
+				Name:  dryRunFlag,
+				Usage: "Write messages to stdout (or to file set by output flag) instead of RabbitMQ",
+				Value: false,
+			},
+			&cli.StringFlag{
+				Name:    outputFlag,
+				Aliases: []string{"o"},
+				Usage:   "Path to file to write messages to instead of RabbitMQ, one message per line ('-' for stdout)",
+			},
+			&cli.StringFlag{
+				Name:  outputFmtFlag,
+				Usage: "Format of messages written instead of RabbitMQ (available: envelope, body; default: envelope)",
+			},
+			&cli.BoolFlag{
+				Name:  confirmFlag,
+				Usage: "Enable publisher confirms: data is considered published only after broker ack",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  mandatoryFlag,
+				Usage: "Enable mandatory publishing: message returned by broker as unroutable is considered failed (enables publisher confirms)",
+				Value: false,
+			},
 			&cli.BoolFlag{
 				Name:  plainTextFlag,
 				Usage: "Enable 'plainText' sending mode, where simply read bytes from a file or string are sent without deserialization, i.e. as is (this mode is incompatible with the following data sources: csv, db; it also disables script)", // nolint:lll
 				Value: false,
 			},
+			&cli.StringFlag{
+				Name:  checkpointFlag,
+				Usage: "Path to state file where the position of the last published data is saved (used for csv, json and db data sources)",
+			},
+			&cli.StringFlag{
+				Name:  resumeFlag,
+				Usage: "Path to state file to resume publishing from; the position keeps being saved to the same file",
+			},
+			&cli.UintFlag{
+				Name:  maxErrorsFlag,
+				Usage: "Max count of records failed to read, convert or publish, which are written to reject file without stopping publishing",
+			},
+			&cli.StringFlag{
+				Name:  rejectFileFlag,
+				Usage: "Path to JSONL file for failed records (used with max-errors)",
+				Value: defaultRejectFilePath,
+			},
+			&cli.DurationFlag{
+				Name:  shutdownFlag,
+				Usage: "Time given to in-flight data to be published after SIGINT/SIGTERM before exit (second signal forces exit)",
+			},
+			&cli.StringFlag{
+				Name:  metricsAddrFlag,
+				Usage: "Address of HTTP listener serving Prometheus metrics on /metrics, e.g. ':9090' (disabled by default)",
+			},
+		),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			return publish(ctx, cmd, reg)
 		},
-		Action: publish,
 	}
 }
 
-func publish(ctx context.Context, cmd *cli.Command) error {
+func sourceFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     sourceFlag,
+			Aliases:  []string{"s"},
+			Required: true,
+			Usage:    "Data source type (available: csv, json, xlsx, parquet, db, rmq, pg-cdc, pg-notify)",
+		},
+		&cli.StringFlag{
+			Name:    filePathFlag,
+			Aliases: []string{"f"},
+			Usage:   "Path to data source file, directory or csv files glob, '-' for stdin (used for csv, json, xlsx and parquet data sources)",
+		},
+		&cli.StringSliceFlag{
+			Name:  queryParamFlag,
+			Usage: "Named parameter of db data source query in 'name=value' format (can be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  scriptFlag,
+			Usage: "Path to file with data conversion script",
+		},
+		&cli.StringFlag{
+			Name:  csvSepFlag,
+			Usage: "Custom csv separator",
+		},
+		&cli.StringFlag{
+			Name:  jsonFormatFlag,
+			Usage: "Format of json data source file (available: ndjson, array)",
+		},
+		&cli.StringFlag{
+			Name:  arrayPathFlag,
+			Usage: "Dot-separated path to array in json data source file of array format, e.g. 'data.items' (root array by default)",
+		},
+		&cli.StringFlag{
+			Name:  recordPathFlag,
+			Usage: "Dot-separated path to field of json record to publish instead of whole record, e.g. 'data' to re-ingest reject file",
+		},
+		&cli.BoolFlag{
+			Name:    recursiveFlag,
+			Aliases: []string{"r"},
+			Usage:   "Read files of data source directory recursively",
+		},
+		&cli.StringSliceFlag{
+			Name:  includeFlag,
+			Usage: "Glob pattern of files of data source directory to read (can be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  excludeFlag,
+			Usage: "Glob pattern of files of data source directory to skip (can be repeated)",
+		},
+		&cli.StringFlag{
+			Name:  sortOrderFlag,
+			Usage: "Order of reading files of data source directory (available: name, mtime, natural)",
+		},
+		&cli.UintFlag{
+			Name:  maxRecordFlag,
+			Usage: "Max size of single line in bytes for json data source file of ndjson format (default: 1 MB)",
+		},
+		&cli.StringFlag{
+			Name:  sheetFlag,
+			Usage: "Name of sheet of xlsx data source (first sheet by default)",
+		},
+		&cli.UintFlag{
+			Name:  sheetIndexFlag,
+			Usage: "Zero-based index of sheet of xlsx data source, used if sheet name is not set",
+		},
+		&cli.StringFlag{
+			Name:  cellRangeFlag,
+			Usage: "Cell range of xlsx data source sheet with header in the first row, e.g. 'B2:F100' (whole sheet by default)",
+		},
+		&cli.StringSliceFlag{
+			Name:  columnFlag,
+			Usage: "Top-level column of parquet data source to read (can be repeated, all columns by default)",
+		},
+	}
+}
+
+func publish(ctx context.Context, cmd *cli.Command, reg *metrics.Registry) error {
 	sourceType := strings.ToLower(cmd.String(sourceFlag))
 	cfg, err := loadAndUpdateConfig(cmd, sourceType)
 	if err != nil {
@@ -101,24 +253,50 @@ func publish(ctx context.Context, cmd *cli.Command) error {
 		return errors.WithMessage(err, "new logger")
 	}
 
-	dataSource, err := defineDataSource(ctx, sourceType, cfg, logger)
+	state, err := loadCheckpoint(sourceType, cfg)
+	if err != nil {
+		return errors.WithMessage(err, "load checkpoint")
+	}
+
+	dataSource, err := defineDataSource(ctx, sourceType, cfg, state, logger)
 	if err != nil {
 		return errors.WithMessage(err, "define source")
 	}
 	defer func() {
-		err := dataSource.Close(ctx)
+		err := dataSource.Close(context.WithoutCancel(ctx))
 		if err != nil {
 			logger.Error(ctx, errors.WithMessage(err, "close data source"))
 		}
 	}()
 
-	target, err := rmq.NewPublisher(ctx, cfg.Target, logger)
+	metricStorage := stats.NewStorage(reg)
+	metricStorage.SetReadPercent(func() *float64 {
+		return dataSource.Progress().ReadDataPercent
+	})
+	if cfg.MetricsAddress != "" {
+		metricsServer := stats.NewServer(cfg.MetricsAddress, reg, logger)
+		metricsServer.Start(ctx)
+		defer func() {
+			err := metricsServer.Shutdown(ctx)
+			if err != nil {
+				logger.Error(ctx, errors.WithMessage(err, "shutdown metrics server"))
+			}
+		}()
+	}
+
+	publisher, err := defineTarget(ctx, cfg, logger, metricStorage)
 	if err != nil {
-		return errors.WithMessage(err, "new rmq publisher")
+		return errors.WithMessage(err, "define target")
 	}
-	defer target.Close()
+	defer func() {
+		err := publisher.Close()
+		if err != nil {
+			logger.Error(ctx, errors.WithMessage(err, "close target"))
+		}
+	}()
 
-	publishAction := action.NewPublish(dataSource, target)
+	publishAction := action.NewPublish(dataSource, publisher, metricStorage, logger).
+		WithShutdownTimeout(cfg.ShutdownTimeout)
 
 	isModeConflict := cfg.ScriptPath != "" && cfg.IsPlainTextMode
 	if isModeConflict {
@@ -129,10 +307,35 @@ func publish(ctx context.Context, cmd *cli.Command) error {
 		if err != nil {
 			return errors.WithMessage(err, "new converter script")
 		}
+		if cfg.OutputPath == target.Stdout {
+			converter = converter.WithConsole(script.NewWriterConsole(os.Stderr))
+		}
 		publishAction = publishAction.WithConverter(converter)
 	}
 	if cfg.ProgressLogInterval > 0 {
-		publishAction = publishAction.LogProgress(cfg.ProgressLogInterval, logger)
+		publishAction = publishAction.LogProgress(cfg.ProgressLogInterval)
+	}
+	if cfg.CheckpointPath != "" {
+		publishAction = publishAction.WithCheckpointer(checkpoint.NewTracker(cfg.CheckpointPath, state))
+	}
+	if cfg.MaxErrors > 0 {
+		rejectWriter, err := reject.NewWriter(cfg.RejectFilePath)
+		if err != nil {
+			return errors.WithMessage(err, "new reject writer")
+		}
+		defer func() {
+			err := rejectWriter.Close()
+			if err != nil {
+				logger.Error(ctx, errors.WithMessage(err, "close reject writer"))
+			}
+			if rejectWriter.Count() > 0 {
+				logger.Warn(ctx, "some records were rejected",
+					log.Any("count", rejectWriter.Count()),
+					log.String("file", cfg.RejectFilePath),
+				)
+			}
+		}()
+		publishAction = publishAction.WithRejects(rejectWriter, cfg.MaxErrors)
 	}
 
 	err = publishAction.Do(ctx, cfg.Target.ShouldPublishSync)
@@ -144,30 +347,55 @@ func publish(ctx context.Context, cmd *cli.Command) error {
 }
 
 // nolint:ireturn
-func defineDataSource(ctx context.Context, sourceType string, cfg conf.Config, logger log.Logger) (domain.DataSource, error) {
+func defineDataSource(
+	ctx context.Context,
+	sourceType string,
+	cfg conf.Config,
+	state domain.Checkpoint,
+	logger log.Logger,
+) (domain.DataSource, error) {
 	switch sourceType {
 	case csvSrc:
-		src, err := source.NewCsv(*cfg.DataSources.Csv)
+		src, err := source.NewCsv(*cfg.DataSources.Csv, state)
 		if err != nil {
 			return nil, errors.WithMessage(err, "new csv data source")
 		}
 		return src, nil
 	case jsonSrc:
 		path := cfg.DataSources.Json.FilePath
+		if cfg.DataSources.Json.Format == jsonArrayFormat {
+			src, err := source.NewJsonArray(path, cfg.DataSources.Json.ArrayPath, cfg.DataSources.Json.RecordPath, cfg.IsPlainTextMode, state)
+			if err != nil {
+				return nil, errors.WithMessage(err, "new json array data source")
+			}
+			return src, nil
+		}
 		if isDir(path) {
-			src, err := source.NewMultipleJson(path, cfg.IsPlainTextMode)
+			src, err := source.NewMultipleJson(ctx, *cfg.DataSources.Json, cfg.IsPlainTextMode, state)
 			if err != nil {
 				return nil, errors.WithMessage(err, "new multiple json data source")
 			}
 			return src, nil
 		}
-		src, err := source.NewJson(path, cfg.IsPlainTextMode)
+		src, err := source.NewJson(*cfg.DataSources.Json, cfg.IsPlainTextMode, state)
 		if err != nil {
 			return nil, errors.WithMessage(err, "new json data source")
 		}
 		return src, nil
+	case xlsxSrc:
+		src, err := source.NewXlsx(*cfg.DataSources.Xlsx, state)
+		if err != nil {
+			return nil, errors.WithMessage(err, "new xlsx data source")
+		}
+		return src, nil
+	case parquetSrc:
+		src, err := source.NewParquet(*cfg.DataSources.Parquet, state)
+		if err != nil {
+			return nil, errors.WithMessage(err, "new parquet data source")
+		}
+		return src, nil
 	case dbSrc:
-		src, err := source.NewDataBase(ctx, *cfg.DataSources.DataBase, logger)
+		src, err := source.NewDataBase(ctx, *cfg.DataSources.DataBase, logger, state)
 		if err != nil {
 			return nil, errors.WithMessage(err, "new db data source")
 		}
@@ -178,11 +406,122 @@ func defineDataSource(ctx context.Context, sourceType string, cfg conf.Config, l
 			return nil, errors.WithMessage(err, "new rabbitmq data source")
 		}
 		return src, nil
+	case pgCdcSrc:
+		src, err := source.NewPgCdc(ctx, *cfg.DataSources.PgCdc, logger)
+		if err != nil {
+			return nil, errors.WithMessage(err, "new pg-cdc data source")
+		}
+		return src, nil
+	case pgNotifySrc:
+		notifyCfg := *cfg.DataSources.PgNotify
+		if notifyCfg.Client.Host == "" && cfg.DataSources.DataBase != nil {
+			notifyCfg.Client = cfg.DataSources.DataBase.Client
+		}
+		src, err := source.NewPgNotify(ctx, notifyCfg, logger, cfg.IsPlainTextMode)
+		if err != nil {
+			return nil, errors.WithMessage(err, "new pg-notify data source")
+		}
+		return src, nil
 	default:
 		return nil, errors.Errorf("unsupported data source '%s'", sourceType)
 	}
 }
 
+type publisher interface {
+	Publish(ctx context.Context, data any, metadata *domain.Metadata) error
+	Close() error
+}
+
+// nolint:ireturn
+func defineTarget(
+	ctx context.Context,
+	cfg conf.Config,
+	logger log.Logger,
+	metricStorage *stats.Storage,
+) (publisher, error) {
+	if cfg.OutputPath != "" {
+		fileTarget, err := target.NewFile(cfg.OutputPath, cfg.OutputFormat, rmq.NewEnvelopeBuilder(cfg.Target))
+		if err != nil {
+			return nil, errors.WithMessage(err, "new file target")
+		}
+		return fileTarget, nil
+	}
+
+	rmqPublisher, err := rmq.NewPublisher(ctx, cfg.Target, logger, metricStorage)
+	if err != nil {
+		return nil, errors.WithMessage(err, "new rmq publisher")
+	}
+	return rmqPublisher, nil
+}
+
+func loadCheckpoint(sourceType string, cfg conf.Config) (domain.Checkpoint, error) {
+	if cfg.CheckpointPath != "" && (sourceType == rmqSrc || sourceType == pgNotifySrc) {
+		return domain.Checkpoint{}, errors.Errorf("checkpoints are not supported for %s data source", sourceType)
+	}
+	if cfg.CheckpointPath != "" && sourceType == pgCdcSrc {
+		return domain.Checkpoint{}, errors.New("checkpoints are not supported for pg-cdc data source, confirmed lsn of replication slot is used instead")
+	}
+	if cfg.CheckpointPath != "" && isStdinSource(sourceType, cfg.DataSources) {
+		return domain.Checkpoint{}, errors.New("checkpoints are not supported for stdin")
+	}
+	if cfg.CheckpointPath != "" && sourceType == dbSrc && cfg.DataSources.DataBase != nil &&
+		cfg.DataSources.DataBase.Query != "" && len(cfg.DataSources.DataBase.PrimaryKey) == 0 {
+		return domain.Checkpoint{}, errors.New("checkpoints are supported for db data source query only with primaryKey, which sets order of rows")
+	}
+	if cfg.CheckpointPath != "" && sourceType == jsonSrc && cfg.DataSources.Json != nil &&
+		cfg.DataSources.Json.IsUnordered {
+		return domain.Checkpoint{}, errors.New("checkpoints are not supported for unordered json data source")
+	}
+	if !cfg.ShouldResume {
+		return domain.Checkpoint{Source: sourceType}, nil
+	}
+
+	state, err := checkpoint.Load(cfg.CheckpointPath)
+	if err != nil {
+		return domain.Checkpoint{}, errors.WithMessage(err, "load checkpoint file")
+	}
+	if state.Source != sourceType {
+		return domain.Checkpoint{}, errors.Errorf("checkpoint was saved for '%s' data source", state.Source)
+	}
+	return state, nil
+}
+
+func isStdinSource(sourceType string, dataSources conf.DataSources) bool {
+	switch {
+	case sourceType == jsonSrc && dataSources.Json != nil:
+		return dataSources.Json.FilePath == utils.StdinPath
+	case sourceType == csvSrc && dataSources.Csv != nil:
+		return dataSources.Csv.FilePath == utils.StdinPath
+	case sourceType == xlsxSrc && dataSources.Xlsx != nil:
+		return dataSources.Xlsx.FilePath == utils.StdinPath
+	default:
+		return false
+	}
+}
+
+func isSourceConfigured(sourceType string, dataSources conf.DataSources) bool {
+	switch sourceType {
+	case csvSrc:
+		return dataSources.Csv != nil
+	case jsonSrc:
+		return dataSources.Json != nil
+	case xlsxSrc:
+		return dataSources.Xlsx != nil
+	case parquetSrc:
+		return dataSources.Parquet != nil
+	case dbSrc:
+		return dataSources.DataBase != nil
+	case rmqSrc:
+		return dataSources.RabbitMq != nil
+	case pgCdcSrc:
+		return dataSources.PgCdc != nil
+	case pgNotifySrc:
+		return dataSources.PgNotify != nil
+	default:
+		return true
+	}
+}
+
 func isDir(filepath string) bool {
 	info, err := os.Stat(filepath)
 	if err != nil {
@@ -203,16 +542,59 @@ func loadAndUpdateConfig(cmd *cli.Command, sourceType string) (conf.Config, erro
 		scriptPath        = strings.TrimSpace(cmd.String(scriptFlag))
 		csvSep            = strings.TrimSpace(cmd.String(csvSepFlag))
 		logInterval       = cmd.Duration(logIntervalFlag)
+		shutdownTimeout   = cmd.Duration(shutdownFlag)
 		enableMsgLogs     = cmd.Bool(logMsgFlag)
 		shouldPublishSync = cmd.Bool(syncFlag)
+		confirmMode       = cmd.Bool(confirmFlag)
+		mandatory         = cmd.Bool(mandatoryFlag)
 		isPlainTextMode   = cmd.Bool(plainTextFlag)
+		checkpointPath    = strings.TrimSpace(cmd.String(checkpointFlag))
+		resumePath        = strings.TrimSpace(cmd.String(resumeFlag))
+		maxErrors         = cmd.Uint(maxErrorsFlag)
+		rejectFilePath    = strings.TrimSpace(cmd.String(rejectFileFlag))
+		queryParams       = cmd.StringSlice(queryParamFlag)
+		metricsAddress    = strings.TrimSpace(cmd.String(metricsAddrFlag))
+		jsonFormat        = strings.TrimSpace(cmd.String(jsonFormatFlag))
+		arrayPath         = strings.TrimSpace(cmd.String(arrayPathFlag))
+		recordPath        = strings.TrimSpace(cmd.String(recordPathFlag))
+		maxRecordSize     = int(cmd.Uint(maxRecordFlag)) // nolint:gosec
+		isRecursive       = cmd.Bool(recursiveFlag)
+		include           = cmd.StringSlice(includeFlag)
+		exclude           = cmd.StringSlice(excludeFlag)
+		sortOrder         = strings.TrimSpace(cmd.String(sortOrderFlag))
+		isDryRun          = cmd.Bool(dryRunFlag)
+		outputPath        = strings.TrimSpace(cmd.String(outputFlag))
+		outputFormat      = strings.TrimSpace(cmd.String(outputFmtFlag))
+		sheet             = strings.TrimSpace(cmd.String(sheetFlag))
+		sheetIndex        = -1
+		cellRange         = strings.TrimSpace(cmd.String(cellRangeFlag))
+		columns           = cmd.StringSlice(columnFlag)
 	)
 
+	if cmd.IsSet(sheetIndexFlag) {
+		sheetIndex = int(cmd.Uint(sheetIndexFlag)) // nolint:gosec
+	}
+
 	switch sourceType {
 	case jsonSrc:
-		updateJsonSrcCfg(&cfg.DataSources, sourcePath)
+		updateJsonSrcCfg(&cfg.DataSources, sourcePath, jsonFormat, arrayPath, recordPath, maxRecordSize)
+		if cfg.DataSources.Json != nil {
+			updateDirectoryCfg(&cfg.DataSources.Json.Directory, isRecursive, include, exclude, sortOrder)
+		}
 	case csvSrc:
 		updateCsvSrcCfg(&cfg.DataSources, sourcePath, csvSep)
+		if cfg.DataSources.Csv != nil {
+			updateDirectoryCfg(&cfg.DataSources.Csv.Directory, isRecursive, include, exclude, sortOrder)
+		}
+	case xlsxSrc:
+		updateXlsxSrcCfg(&cfg.DataSources, sourcePath, sheet, sheetIndex, cellRange)
+	case parquetSrc:
+		updateParquetSrcCfg(&cfg.DataSources, sourcePath, columns)
+	case dbSrc:
+		err = updateDbSrcCfg(&cfg.DataSources, queryParams)
+		if err != nil {
+			return conf.Config{}, errors.WithMessage(err, "update db data source config")
+		}
 	}
 
 	if scriptPath != "" {
@@ -221,11 +603,36 @@ func loadAndUpdateConfig(cmd *cli.Command, sourceType string) (conf.Config, erro
 	if logInterval > 0 {
 		cfg.ProgressLogInterval = logInterval
 	}
+	if shutdownTimeout > 0 {
+		cfg.ShutdownTimeout = shutdownTimeout
+	}
 
 	cfg.Target.EnableMessageLogs = enableMsgLogs
 	cfg.Target.ShouldPublishSync = shouldPublishSync
+	cfg.Target.ConfirmMode = cfg.Target.ConfirmMode || confirmMode
+	cfg.Target.Mandatory = cfg.Target.Mandatory || mandatory
 	cfg.IsPlainTextMode = isPlainTextMode
+	cfg.CheckpointPath = checkpointPath
+	if resumePath != "" {
+		cfg.CheckpointPath = resumePath
+		cfg.ShouldResume = true
+	}
+	cfg.MaxErrors = maxErrors
+	cfg.RejectFilePath = rejectFilePath
+	if metricsAddress != "" {
+		cfg.MetricsAddress = metricsAddress
+	}
+	cfg.OutputPath = outputPath
+	if outputFormat != "" {
+		cfg.OutputFormat = outputFormat
+	}
+	if isDryRun && cfg.OutputPath == "" {
+		cfg.OutputPath = target.Stdout
+	}
 
+	if !isSourceConfigured(sourceType, cfg.DataSources) {
+		return conf.Config{}, errors.Errorf("config of '%s' data source is required", sourceType)
+	}
 	err = validator.Default.ValidateToError(cfg)
 	if err != nil {
 		return conf.Config{}, errors.WithMessage(err, "validate config")
@@ -233,9 +640,55 @@ func loadAndUpdateConfig(cmd *cli.Command, sourceType string) (conf.Config, erro
 	return cfg, nil
 }
 
-func updateJsonSrcCfg(dataSrc *conf.DataSources, srcPath string) {
+func updateJsonSrcCfg(
+	dataSrc *conf.DataSources,
+	srcPath string,
+	format string,
+	arrayPath string,
+	recordPath string,
+	maxRecordSize int,
+) {
+	if srcPath == "" && format == "" && arrayPath == "" && recordPath == "" && maxRecordSize == 0 {
+		return
+	}
+
+	if dataSrc.Json == nil {
+		dataSrc.Json = new(conf.JsonDataSource)
+	}
 	if srcPath != "" {
-		dataSrc.Json = &conf.JsonDataSource{FilePath: srcPath}
+		dataSrc.Json.FilePath = srcPath
+	}
+	if format != "" {
+		dataSrc.Json.Format = format
+	}
+	if arrayPath != "" {
+		dataSrc.Json.Format = jsonArrayFormat
+		dataSrc.Json.ArrayPath = arrayPath
+	}
+	if recordPath != "" {
+		dataSrc.Json.RecordPath = recordPath
+	}
+	if maxRecordSize > 0 {
+		dataSrc.Json.MaxRecordSize = maxRecordSize
+	}
+}
+
+func updateDirectoryCfg(
+	dir *conf.Directory,
+	isRecursive bool,
+	include []string,
+	exclude []string,
+	sortOrder string,
+) {
+	dir.IsRecursive = dir.IsRecursive || isRecursive
+	if len(include) > 0 {
+		dir.Include = include
+	}
+	if len(exclude) > 0 {
+		dir.Exclude = exclude
+	}
+	if sortOrder != "" {
+		dir.SortOrder = sortOrder
 	}
 }
 
@@ -254,3 +707,61 @@ func updateCsvSrcCfg(dataSrc *conf.DataSources, srcPath string, sep string) {
 		dataSrc.Csv.Sep = sep
 	}
 }
+
+// updateXlsxSrcCfg updates xlsx data source config, negative sheetIndex means it is not set.
+func updateXlsxSrcCfg(dataSrc *conf.DataSources, srcPath string, sheet string, sheetIndex int, cellRange string) {
+	if srcPath == "" && sheet == "" && sheetIndex < 0 && cellRange == "" {
+		return
+	}
+
+	if dataSrc.Xlsx == nil {
+		dataSrc.Xlsx = new(conf.XlsxDataSource)
+	}
+	if srcPath != "" {
+		dataSrc.Xlsx.FilePath = srcPath
+	}
+	if sheetIndex >= 0 {
+		dataSrc.Xlsx.Sheet = ""
+		dataSrc.Xlsx.SheetIndex = sheetIndex
+	}
+	if sheet != "" {
+		dataSrc.Xlsx.Sheet = sheet
+	}
+	if cellRange != "" {
+		dataSrc.Xlsx.Range = cellRange
+	}
+}
+
+func updateParquetSrcCfg(dataSrc *conf.DataSources, srcPath string, columns []string) {
+	if srcPath == "" && len(columns) == 0 {
+		return
+	}
+
+	if dataSrc.Parquet == nil {
+		dataSrc.Parquet = new(conf.ParquetDataSource)
+	}
+	if srcPath != "" {
+		dataSrc.Parquet.FilePath = srcPath
+	}
+	if len(columns) > 0 {
+		dataSrc.Parquet.SelectedColumns = columns
+	}
+}
+
+func updateDbSrcCfg(dataSrc *conf.DataSources, queryParams []string) error {
+	if len(queryParams) == 0 || dataSrc.DataBase == nil {
+		return nil
+	}
+
+	if dataSrc.DataBase.QueryParams == nil {
+		dataSrc.DataBase.QueryParams = make(map[string]any, len(queryParams))
+	}
+	for _, param := range queryParams {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return errors.Errorf("invalid query param '%s', expected 'name=value'", param)
+		}
+		dataSrc.DataBase.QueryParams[strings.TrimSpace(name)] = value
+	}
+	return nil
+}
@@ -22,7 +22,15 @@ type Config struct {
 	DataSources         DataSources
 	Target              Target
 	ProgressLogInterval time.Duration
+	ShutdownTimeout     time.Duration
 	IsPlainTextMode     bool
+	CheckpointPath      string
+	ShouldResume        bool
+	MaxErrors           uint64
+	RejectFilePath      string
+	MetricsAddress      string
+	OutputPath          string
+	OutputFormat        string `validate:"omitempty,oneof=envelope body"`
 }
 
 type DataSources struct {
@@ -30,41 +38,127 @@ type DataSources struct {
 	RabbitMq *RabbitMqDataSource
 	Csv      *CsvDataSource
 	Json     *JsonDataSource
+	Xlsx     *XlsxDataSource
+	Parquet  *ParquetDataSource
+	PgCdc    *PgCdcDataSource
+	PgNotify *PgNotifyDataSource
 }
 
 type DbDataSource struct {
-	Client          dbx.Config
-	Table           string   `validate:"required"`
+	Dialect         string     `validate:"omitempty,oneof=postgres mysql sqlite"`
+	Client          dbx.Config `validate:"-"`
+	Dsn             string
+	Table           string   `validate:"required_without=Query"`
 	Parallel        int      `validate:"required,min=1"`
 	BatchSize       uint64   `validate:"required,min=100"`
-	PrimaryKey      []string `validate:"required,min=1"`
+	PrimaryKey      []string `validate:"required_without=Query"`
 	SelectedColumns []string
 	WhereClause     string
+	ReadStrategy    string `validate:"omitempty,oneof=materializedView keyset"`
+	Query           string
+	QueryParams     map[string]any
+	PartitionKey    string
 }
 
 type RabbitMqDataSource struct {
-	Client         grmqx.Connection
-	Consumer       grmqx.Consumer
-	ConsumeTimeout time.Duration
+	Client          grmqx.Connection
+	Consumer        grmqx.Consumer
+	ConsumeTimeout  time.Duration
+	AckAfterPublish bool
+	RequeueOnError  bool
 }
 
 type CsvDataSource struct {
-	FilePath string `validate:"required"`
-	Sep      string `validate:"required"`
+	FilePath     string `validate:"required"`
+	Sep          string `validate:"required"`
+	HeaderMode   string `validate:"omitempty,oneof=fail merge reorder"`
+	Directory    Directory
+	Columns      []CsvColumn `validate:"dive"`
+	IsInferTypes bool
+	NullToken    *string
+}
+
+type CsvColumn struct {
+	Name       string `validate:"required"`
+	Type       string `validate:"required,oneof=string int float bool json timestamp"`
+	Layout     string
+	IsNullable bool
 }
 
 type JsonDataSource struct {
-	FilePath string `validate:"required"`
+	FilePath      string `validate:"required"`
+	Format        string `validate:"omitempty,oneof=ndjson array"`
+	ArrayPath     string
+	RecordPath    string
+	MaxRecordSize int `validate:"min=0"`
+	Parallel      int `validate:"min=0"`
+	IsUnordered   bool
+	FileMode      string `validate:"omitempty,oneof=whole ndjson"`
+	Directory     Directory
+}
+
+type XlsxDataSource struct {
+	FilePath   string `validate:"required"`
+	Sheet      string
+	SheetIndex int `validate:"min=0"`
+	Range      string
+}
+
+type ParquetDataSource struct {
+	FilePath        string `validate:"required"`
+	SelectedColumns []string
+}
+
+type PgCdcDataSource struct {
+	Client         dbx.Config `validate:"-"`
+	Dsn            string
+	Slot           string `validate:"required"`
+	Plugin         string `validate:"omitempty,oneof=pgoutput wal2json"`
+	Publications   []string
+	CreateSlot     bool
+	StatusInterval time.Duration
+	Snapshot       *DbDataSource
+}
+
+type PgNotifyDataSource struct {
+	Client         dbx.Config `validate:"-"`
+	Channels       []string   `validate:"required,min=1"`
+	RequestIdField string
+	ConsumeTimeout time.Duration
+}
+
+type Directory struct {
+	IsRecursive bool
+	Include     []string
+	Exclude     []string
+	SortOrder   string `validate:"omitempty,oneof=name mtime natural"`
 }
 
 type Target struct {
 	Client            grmqx.Connection
 	Publisher         grmqx.Publisher
 	Rps               int `validate:"required,min=1"`
+	ConfirmMode       bool
+	ConfirmWindow     int `validate:"min=0"`
+	Mandatory         bool
+	Message           Message
 	EnableMessageLogs bool
 	ShouldPublishSync bool
 }
 
+type Message struct {
+	IsEnvelope         bool
+	ExchangeField      string
+	RoutingKeyField    string
+	HeadersField       string
+	MessageIdField     string
+	CorrelationIdField string
+	ContentTypeField   string
+	PriorityField      string
+	ExpirationField    string
+	TimestampField     string
+}
+
 func LoadConfig(isDev bool) (Config, error) {
 	cfgPath, err := getConfigFilePath(isDev)
 	if err != nil {
@@ -0,0 +1,93 @@
+package domain
+
+type Position interface {
+	Apply(checkpoint *Checkpoint)
+}
+
+type Checkpoint struct {
+	Source    string
+	Offset    int64
+	FileIdx   int
+	RowNums   map[int]int64
+	Keys      map[int][]KeyValue
+	KeyRanges []KeyRange
+	Parallel  int
+}
+
+type OffsetPosition int64
+
+func (p OffsetPosition) Apply(checkpoint *Checkpoint) {
+	checkpoint.Offset = int64(p)
+}
+
+type FileIdxPosition int
+
+func (p FileIdxPosition) Apply(checkpoint *Checkpoint) {
+	checkpoint.FileIdx = int(p)
+}
+
+type FilePosition struct {
+	FileIdx int
+	Offset  int64
+}
+
+func (p FilePosition) Apply(checkpoint *Checkpoint) {
+	checkpoint.FileIdx = p.FileIdx
+	checkpoint.Offset = p.Offset
+}
+
+// FileRowPosition is FilePosition with number of the last read row of the file,
+// which is stored as row number of worker 0.
+type FileRowPosition struct {
+	FileIdx int
+	Offset  int64
+	RowNum  int64
+}
+
+func (p FileRowPosition) Apply(checkpoint *Checkpoint) {
+	FilePosition{FileIdx: p.FileIdx, Offset: p.Offset}.Apply(checkpoint)
+	RowNumPosition{WorkerIdx: 0, RowNum: p.RowNum}.Apply(checkpoint)
+}
+
+type RowNumPosition struct {
+	WorkerIdx int
+	RowNum    int64
+}
+
+func (p RowNumPosition) Apply(checkpoint *Checkpoint) {
+	if checkpoint.RowNums == nil {
+		checkpoint.RowNums = make(map[int]int64)
+	}
+	checkpoint.RowNums[p.WorkerIdx] = p.RowNum
+}
+
+// KeyValue is a value of key column stored as string with its type,
+// so it is restored without precision loss, e.g. for integers beyond float64 range or binary keys.
+type KeyValue struct {
+	Type  string
+	Value string
+}
+
+// KeyRange is range of keys read by worker, nil bound means unbounded range.
+type KeyRange struct {
+	Lower []KeyValue
+	Upper []KeyValue
+}
+
+// KeyPosition is the last key read by worker along with ranges of all workers,
+// which are reused on resume, so that rows are distributed between workers in the same way.
+type KeyPosition struct {
+	WorkerIdx int
+	Key       []KeyValue
+	KeyRanges []KeyRange
+	Parallel  int
+}
+
+func (p KeyPosition) Apply(checkpoint *Checkpoint) {
+	if checkpoint.Keys == nil {
+		checkpoint.Keys = make(map[int][]KeyValue)
+	}
+	checkpoint.Keys[p.WorkerIdx] = p.Key
+	checkpoint.KeyRanges = p.KeyRanges
+	checkpoint.Parallel = p.Parallel
+}
@@ -1,6 +1,45 @@
 package domain
 
+import (
+	"time"
+)
+
 type Payload struct {
 	RequestId string
 	Data      any
+	// Raw is the record as it was read from data source, if it is set, it is written to reject file instead of Data.
+	Raw          []byte
+	Metadata     *Metadata
+	Position     Position
+	Acknowledger Acknowledger
+}
+
+type Acknowledger interface {
+	Ack() error
+	Nack() error
+}
+
+type Metadata struct {
+	Headers         map[string]any `json:"headers"`
+	ContentType     string         `json:"contentType"`
+	ContentEncoding string         `json:"contentEncoding"`
+	Priority        uint8          `json:"priority"`
+	CorrelationId   string         `json:"correlationId"`
+	ReplyTo         string         `json:"replyTo"`
+	Expiration      string         `json:"expiration"`
+	MessageId       string         `json:"messageId"`
+	Timestamp       time.Time      `json:"timestamp"`
+	Type            string         `json:"type"`
+	UserId          string         `json:"userId"`
+	AppId           string         `json:"appId"`
+
+	// SourceFile and SourceRow describe origin of data read from file, they are not published.
+	SourceFile string `json:"sourceFile"`
+	SourceRow  int64  `json:"sourceRow"`
+}
+
+func NewMetadata() *Metadata {
+	return &Metadata{
+		Headers: make(map[string]any),
+	}
 }
@@ -0,0 +1,38 @@
+package domain
+
+const (
+	ReadStage    = "read"
+	ConvertStage = "convert"
+	PublishStage = "publish"
+)
+
+type RecordError struct {
+	Data         []byte
+	Position     Position
+	Acknowledger Acknowledger
+	Err          error
+}
+
+func NewRecordError(err error, data []byte, position Position) *RecordError {
+	return &RecordError{
+		Data:         data,
+		Position:     position,
+		Acknowledger: nil,
+		Err:          err,
+	}
+}
+
+func (e *RecordError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+type Reject struct {
+	Stage     string
+	Error     string
+	RequestId string
+	Data      any
+}
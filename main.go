@@ -4,13 +4,16 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/txix-open/isp-kit/metrics"
 	"github.com/txix-open/mqpusher/command"
 	"github.com/urfave/cli/v3"
 )
 
 const (
-	version = "2.0.0"
+	version = "2.1.0"
 )
 
 func main() {
@@ -19,12 +22,33 @@ func main() {
 		Version: version,
 		Usage:   "The mqpusher tool is designed to transfer data from various source to a single RabbitMQ queue",
 		Commands: []*cli.Command{
-			command.Publish(),
+			command.Publish(metrics.DefaultRegistry),
+			command.Preview(),
 			command.GenerateConfig(),
 		},
 	}
-	err := cmd.Run(context.Background(), os.Args)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignals(cancel)
+
+	err := cmd.Run(ctx, os.Args)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(err) // nolint:gocritic
 	}
 }
+
+// handleSignals cancels ctx on the first signal to shut down gracefully
+// and forces exit on the second one.
+func handleSignals(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 2) // nolint:mnd
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signals
+	log.Printf("received %s, shutting down gracefully; repeat to force exit", sig)
+	cancel()
+
+	sig = <-signals
+	log.Printf("received %s, forcing exit", sig)
+	os.Exit(1)
+}
@@ -0,0 +1,87 @@
+package reject
+
+import (
+	stdjson "encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+type record struct {
+	Stage     string
+	Error     string
+	RequestId string
+	Data      json.RawMessage
+}
+
+type Writer struct {
+	file    *os.File
+	lock    *sync.Mutex
+	counter *atomic.Uint64
+}
+
+func NewWriter(filePath string) (*Writer, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) // nolint:mnd,gosec
+	if err != nil {
+		return nil, errors.WithMessagef(err, "open file '%s'", filePath)
+	}
+	return &Writer{
+		file:    file,
+		lock:    new(sync.Mutex),
+		counter: new(atomic.Uint64),
+	}, nil
+}
+
+func (w *Writer) Write(reject domain.Reject) error {
+	data, err := rawData(reject.Data)
+	if err != nil {
+		return errors.WithMessage(err, "raw data")
+	}
+	bytes, err := json.Marshal(record{
+		Stage:     reject.Stage,
+		Error:     reject.Error,
+		RequestId: reject.RequestId,
+		Data:      data,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "marshal reject")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err = w.file.Write(append(bytes, '\n'))
+	if err != nil {
+		return errors.WithMessage(err, "write reject")
+	}
+	w.counter.Add(1)
+
+	return nil
+}
+
+func (w *Writer) Count() uint64 {
+	return w.counter.Load()
+}
+
+func (w *Writer) Close() error {
+	err := w.file.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close file")
+	}
+	return nil
+}
+
+func rawData(data any) (json.RawMessage, error) {
+	bytes, isPlainText := data.([]byte)
+	if !isPlainText {
+		return json.Marshal(data)
+	}
+	if stdjson.Valid(bytes) {
+		return bytes, nil
+	}
+	return json.Marshal(string(bytes))
+}
@@ -0,0 +1,289 @@
+package rmq
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/txix-open/isp-kit/grmqx"
+)
+
+const (
+	dialLocale = "en_US"
+)
+
+var (
+	ErrReturned      = errors.New("message was returned by broker")
+	errChannelClosed = errors.New("channel closed")
+)
+
+type confirmer struct {
+	url         string
+	isMandatory bool
+	window      chan struct{}
+
+	lock    *sync.Mutex
+	channel *confirmChannel
+}
+
+func newConfirmer(connection grmqx.Connection, window int, isMandatory bool) (*confirmer, error) {
+	c := &confirmer{
+		url:         connection.Url(),
+		isMandatory: isMandatory,
+		window:      make(chan struct{}, window),
+		lock:        new(sync.Mutex),
+		channel:     nil,
+	}
+	_, err := c.currentChannel()
+	if err != nil {
+		return nil, errors.WithMessage(err, "open confirm channel")
+	}
+	return c, nil
+}
+
+func (c *confirmer) Publish(ctx context.Context, exchange string, routingKey string, msg *amqp091.Publishing) error {
+	select {
+	case c.window <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.window }()
+
+	channel, err := c.currentChannel()
+	if err != nil {
+		return errors.WithMessage(err, "open confirm channel")
+	}
+	result, err := channel.publish(ctx, exchange, routingKey, c.isMandatory, msg)
+	if err != nil {
+		return errors.WithMessage(err, "publish")
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *confirmer) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.channel != nil {
+		c.channel.close()
+	}
+}
+
+func (c *confirmer) currentChannel() (*confirmChannel, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.channel != nil && !c.channel.isClosed() {
+		return c.channel, nil
+	}
+	if c.channel != nil {
+		c.channel.close()
+	}
+
+	// closed channel is dialed again on next publish, so publish retries reconnect
+	channel, err := dialConfirmChannel(c.url)
+	if err != nil {
+		return nil, err
+	}
+	c.channel = channel
+	return channel, nil
+}
+
+type confirmChannel struct {
+	conn *amqp091.Connection
+	ch   *amqp091.Channel
+
+	publishLock *sync.Mutex
+	pendingLock *sync.Mutex
+	pending     map[uint64]*pendingPublish
+	closeErr    error
+}
+
+type pendingPublish struct {
+	result     chan error
+	exchange   string
+	routingKey string
+	body       []byte
+	returned   *amqp091.Return
+}
+
+// dialConfirmChannel dials connection with the same config as grmqx client.
+func dialConfirmChannel(url string) (*confirmChannel, error) {
+	conn, err := amqp091.DialConfig(url, amqp091.Config{
+		Heartbeat: grmqx.DefaultHeartbeat,
+		Locale:    dialLocale,
+		Dial:      amqp091.DefaultDial(grmqx.DefaultDialTimeout),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "dial")
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.WithMessage(err, "open channel")
+	}
+	err = ch.Confirm(false)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.WithMessage(err, "run channel in confirmation mode")
+	}
+
+	c := &confirmChannel{
+		conn:        conn,
+		ch:          ch,
+		publishLock: new(sync.Mutex),
+		pendingLock: new(sync.Mutex),
+		pending:     make(map[uint64]*pendingPublish),
+		closeErr:    nil,
+	}
+	go c.dispatch(
+		ch.NotifyPublish(make(chan amqp091.Confirmation)),
+		ch.NotifyReturn(make(chan amqp091.Return)),
+		ch.NotifyClose(make(chan *amqp091.Error, 1)),
+	)
+
+	return c, nil
+}
+
+func (c *confirmChannel) publish(
+	ctx context.Context,
+	exchange string,
+	routingKey string,
+	isMandatory bool,
+	msg *amqp091.Publishing,
+) (<-chan error, error) {
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+
+	seq := c.ch.GetNextPublishSeqNo()
+	result := make(chan error, 1)
+	c.pendingLock.Lock()
+	if c.closeErr != nil {
+		c.pendingLock.Unlock()
+		return nil, c.closeErr
+	}
+	c.pending[seq] = &pendingPublish{
+		result:     result,
+		exchange:   exchange,
+		routingKey: routingKey,
+		body:       msg.Body,
+		returned:   nil,
+	}
+	c.pendingLock.Unlock()
+
+	err := c.ch.PublishWithContext(ctx, exchange, routingKey, isMandatory, false, *msg)
+	if err != nil {
+		c.pendingLock.Lock()
+		delete(c.pending, seq)
+		c.pendingLock.Unlock()
+		return nil, errors.WithMessage(err, "publish with context")
+	}
+
+	return result, nil
+}
+
+func (c *confirmChannel) dispatch(
+	confirms <-chan amqp091.Confirmation,
+	returns <-chan amqp091.Return,
+	closes <-chan *amqp091.Error,
+) {
+	for {
+		select {
+		case confirmation, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			c.handleConfirmation(confirmation)
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.handleReturn(ret)
+		case err := <-closes:
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *confirmChannel) handleConfirmation(confirmation amqp091.Confirmation) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	pending, ok := c.pending[confirmation.DeliveryTag]
+	if !ok {
+		return
+	}
+	delete(c.pending, confirmation.DeliveryTag)
+
+	switch {
+	case pending.returned != nil:
+		pending.result <- errors.WithMessagef(ErrReturned, "code = %d, text = %s", pending.returned.ReplyCode, pending.returned.ReplyText)
+	case !confirmation.Ack:
+		pending.result <- errors.Errorf("message with tag %d was not acked by broker", confirmation.DeliveryTag)
+	default:
+		pending.result <- nil
+	}
+}
+
+// handleReturn marks the earliest pending publish of returned message.
+// Broker sends basic.return before ack of the message and both are dispatched in order they are received,
+// so the returned message is still pending. Messages with the same exchange, routing key and body are routed alike,
+// so any of them would be returned.
+func (c *confirmChannel) handleReturn(ret amqp091.Return) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	var (
+		earliest    *pendingPublish
+		earliestSeq uint64
+	)
+	for seq, pending := range c.pending {
+		isSame := pending.returned == nil &&
+			pending.exchange == ret.Exchange &&
+			pending.routingKey == ret.RoutingKey &&
+			bytes.Equal(pending.body, ret.Body)
+		if isSame && (earliest == nil || seq < earliestSeq) {
+			earliest = pending
+			earliestSeq = seq
+		}
+	}
+	if earliest != nil {
+		earliest.returned = &ret
+	}
+}
+
+func (c *confirmChannel) fail(err *amqp091.Error) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	c.closeErr = errChannelClosed
+	if err != nil {
+		c.closeErr = errors.WithMessage(err, errChannelClosed.Error())
+	}
+	for seq, pending := range c.pending {
+		pending.result <- c.closeErr
+		delete(c.pending, seq)
+	}
+}
+
+func (c *confirmChannel) isClosed() bool {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	return c.closeErr != nil
+}
+
+func (c *confirmChannel) close() {
+	_ = c.conn.Close()
+}
@@ -0,0 +1,327 @@
+package rmq
+
+import (
+	stdjson "encoding/json"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
+)
+
+const (
+	envelopeBodyField = "body"
+)
+
+var (
+	// timestampLayouts are layouts of string timestamp, time without zone is UTC.
+	timestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		time.DateTime,
+		time.DateOnly,
+	}
+)
+
+type message struct {
+	exchange   string
+	routingKey string
+	publishing amqp091.Publishing
+}
+
+type messageBuilder struct {
+	cfg        conf.Message
+	exchange   string
+	routingKey string
+}
+
+func newMessageBuilder(cfg conf.Message, exchange string, routingKey string) messageBuilder {
+	if cfg.IsEnvelope {
+		cfg = envelopeFields(cfg)
+	}
+	return messageBuilder{
+		cfg:        cfg,
+		exchange:   exchange,
+		routingKey: routingKey,
+	}
+}
+
+func envelopeFields(cfg conf.Message) conf.Message {
+	setDefault := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setDefault(&cfg.ExchangeField, "exchange")
+	setDefault(&cfg.RoutingKeyField, "routingKey")
+	setDefault(&cfg.HeadersField, "headers")
+	setDefault(&cfg.MessageIdField, "messageId")
+	setDefault(&cfg.CorrelationIdField, "correlationId")
+	setDefault(&cfg.ContentTypeField, "contentType")
+	setDefault(&cfg.PriorityField, "priority")
+	setDefault(&cfg.ExpirationField, "expiration")
+	setDefault(&cfg.TimestampField, "timestamp")
+	return cfg
+}
+
+// nolint:cyclop
+func (b messageBuilder) Build(data any, metadata *domain.Metadata) (*message, error) {
+	result := &message{
+		exchange:   b.exchange,
+		routingKey: b.routingKey,
+		publishing: amqp091.Publishing{},
+	}
+	if metadata != nil {
+		publishing, err := publishingFromMetadata(*metadata)
+		if err != nil {
+			return nil, errors.WithMessage(err, "publishing from metadata")
+		}
+		result.publishing = publishing
+	}
+
+	body := data
+	if b.cfg.IsEnvelope {
+		envelope, ok := data.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("envelope must be an object, got %T", data)
+		}
+		body, ok = envelope[envelopeBodyField]
+		if !ok {
+			return nil, errors.Errorf("envelope field '%s' is required", envelopeBodyField)
+		}
+	}
+	bytes, err := marshalBody(body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "marshal body")
+	}
+	result.publishing.Body = bytes
+
+	setString := func(field string, dst *string) {
+		v, ok := utils.LookupField(data, field)
+		if ok {
+			*dst = utils.FieldString(v)
+		}
+	}
+	setString(b.cfg.ExchangeField, &result.exchange)
+	setString(b.cfg.RoutingKeyField, &result.routingKey)
+	setString(b.cfg.MessageIdField, &result.publishing.MessageId)
+	setString(b.cfg.CorrelationIdField, &result.publishing.CorrelationId)
+	setString(b.cfg.ContentTypeField, &result.publishing.ContentType)
+	setString(b.cfg.ExpirationField, &result.publishing.Expiration)
+
+	if v, ok := utils.LookupField(data, b.cfg.HeadersField); ok {
+		headers, err := toHeaders(v)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "field '%s'", b.cfg.HeadersField)
+		}
+		if result.publishing.Headers == nil {
+			result.publishing.Headers = make(amqp091.Table, len(headers))
+		}
+		maps.Copy(result.publishing.Headers, headers)
+	}
+	if v, ok := utils.LookupField(data, b.cfg.PriorityField); ok {
+		result.publishing.Priority, err = toPriority(v)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "field '%s'", b.cfg.PriorityField)
+		}
+	}
+	if v, ok := utils.LookupField(data, b.cfg.TimestampField); ok {
+		result.publishing.Timestamp, err = toTimestamp(v)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "field '%s'", b.cfg.TimestampField)
+		}
+	}
+
+	return result, nil
+}
+
+// Envelope is a message resolved by message builder in format of data with target.message.isEnvelope,
+// so it can be published again as is.
+type Envelope struct {
+	Exchange      string         `json:"exchange"`
+	RoutingKey    string         `json:"routingKey"`
+	Headers       map[string]any `json:"headers,omitempty"`
+	MessageId     string         `json:"messageId,omitempty"`
+	CorrelationId string         `json:"correlationId,omitempty"`
+	ContentType   string         `json:"contentType,omitempty"`
+	Priority      uint8          `json:"priority,omitempty"`
+	Expiration    string         `json:"expiration,omitempty"`
+	Timestamp     *time.Time     `json:"timestamp,omitempty"`
+	Body          any            `json:"body"`
+	// RawBody is message body as it would be published.
+	RawBody []byte `json:"-"`
+}
+
+type EnvelopeBuilder struct {
+	messageBuilder messageBuilder
+}
+
+func NewEnvelopeBuilder(cfg conf.Target) EnvelopeBuilder {
+	return EnvelopeBuilder{
+		messageBuilder: newMessageBuilder(cfg.Message, cfg.Publisher.Exchange, cfg.Publisher.RoutingKey),
+	}
+}
+
+// Build resolves message of data, body which is not valid JSON is set as string.
+func (b EnvelopeBuilder) Build(data any, metadata *domain.Metadata) (Envelope, error) {
+	msg, err := b.messageBuilder.Build(data, metadata)
+	if err != nil {
+		return Envelope{}, errors.WithMessage(err, "build message")
+	}
+
+	envelope := Envelope{
+		Exchange:      msg.exchange,
+		RoutingKey:    msg.routingKey,
+		Headers:       msg.publishing.Headers,
+		MessageId:     msg.publishing.MessageId,
+		CorrelationId: msg.publishing.CorrelationId,
+		ContentType:   msg.publishing.ContentType,
+		Priority:      msg.publishing.Priority,
+		Expiration:    msg.publishing.Expiration,
+		Timestamp:     nil,
+		Body:          string(msg.publishing.Body),
+		RawBody:       msg.publishing.Body,
+	}
+	if !msg.publishing.Timestamp.IsZero() {
+		envelope.Timestamp = &msg.publishing.Timestamp
+	}
+	if stdjson.Valid(msg.publishing.Body) {
+		envelope.Body = json.RawMessage(msg.publishing.Body)
+	}
+	return envelope, nil
+}
+
+func publishingFromMetadata(metadata domain.Metadata) (amqp091.Publishing, error) {
+	publishing := amqp091.Publishing{
+		ContentType:     metadata.ContentType,
+		ContentEncoding: metadata.ContentEncoding,
+		Priority:        metadata.Priority,
+		CorrelationId:   metadata.CorrelationId,
+		ReplyTo:         metadata.ReplyTo,
+		Expiration:      metadata.Expiration,
+		MessageId:       metadata.MessageId,
+		Timestamp:       metadata.Timestamp,
+		Type:            metadata.Type,
+		UserId:          metadata.UserId,
+		AppId:           metadata.AppId,
+	}
+	if len(metadata.Headers) > 0 {
+		headers, err := toHeaders(metadata.Headers)
+		if err != nil {
+			return amqp091.Publishing{}, errors.WithMessage(err, "headers")
+		}
+		publishing.Headers = headers
+	}
+	return publishing, nil
+}
+
+func marshalBody(body any) ([]byte, error) {
+	bytes, isPlainText := body.([]byte)
+	if isPlainText {
+		return bytes, nil
+	}
+	bytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "json marshal")
+	}
+	return bytes, nil
+}
+
+func toHeaders(v any) (amqp091.Table, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.Errorf("headers must be an object, got %T", v)
+	}
+	headers := make(amqp091.Table, len(obj))
+	for key, value := range obj {
+		headers[key] = toHeaderValue(value)
+	}
+	err := headers.Validate()
+	if err != nil {
+		return nil, errors.WithMessage(err, "validate headers")
+	}
+	return headers, nil
+}
+
+func toHeaderValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		table := make(amqp091.Table, len(value))
+		for key, item := range value {
+			table[key] = toHeaderValue(item)
+		}
+		return table
+	case []any:
+		list := make([]any, len(value))
+		for i, item := range value {
+			list[i] = toHeaderValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func toPriority(v any) (uint8, error) {
+	var (
+		priority int64
+		err      error
+	)
+	switch value := v.(type) {
+	case int64:
+		priority = value
+	case int:
+		priority = int64(value)
+	case float64:
+		if value != math.Trunc(value) || value < 0 || value > math.MaxUint8 {
+			return 0, errors.Errorf("priority %v is not an integer in range [0, 255]", value)
+		}
+		priority = int64(value)
+	case string:
+		priority, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, errors.WithMessage(err, "parse priority")
+		}
+	default:
+		return 0, errors.Errorf("unexpected priority type %T", v)
+	}
+	if priority < 0 || priority > 255 {
+		return 0, errors.Errorf("priority %d is out of range [0, 255]", priority)
+	}
+	return uint8(priority), nil
+}
+
+func toTimestamp(v any) (time.Time, error) {
+	switch value := v.(type) {
+	case time.Time:
+		return value, nil
+	case int64:
+		return time.Unix(value, 0), nil
+	case int:
+		return time.Unix(int64(value), 0), nil
+	case float64:
+		seconds, fraction := math.Modf(value)
+		return time.Unix(int64(seconds), int64(fraction*float64(time.Second))), nil
+	case string:
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0), nil
+		}
+		for _, layout := range timestampLayouts {
+			timestamp, err := time.Parse(layout, value)
+			if err == nil {
+				return timestamp, nil
+			}
+		}
+		return time.Time{}, errors.Errorf("parse timestamp '%s': expected unix seconds or time in RFC3339, '%s' or '%s' format",
+			value, time.DateTime, time.DateOnly)
+	default:
+		return time.Time{}, errors.Errorf("unexpected timestamp type %T", v)
+	}
+}
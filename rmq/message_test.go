@@ -0,0 +1,95 @@
+package rmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToPriority(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    any
+		expected uint8
+		isErr    bool
+	}{
+		{name: "int64", value: int64(5), expected: 5},
+		{name: "int", value: 255, expected: 255},
+		{name: "float64", value: float64(3), expected: 3},
+		{name: "string", value: "7", expected: 7},
+		{name: "zero", value: 0, expected: 0},
+		{name: "negative int", value: -1, isErr: true},
+		{name: "int above range", value: int64(256), isErr: true},
+		{name: "fractional float64", value: 1.5, isErr: true},
+		{name: "float64 above range", value: float64(300), isErr: true},
+		{name: "negative float64", value: float64(-1), isErr: true},
+		{name: "string above range", value: "1000", isErr: true},
+		{name: "invalid string", value: "high", isErr: true},
+		{name: "unexpected type", value: true, isErr: true},
+		{name: "nil", value: nil, isErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := toPriority(test.value)
+			if test.isErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestToTimestamp(t *testing.T) {
+	t.Parallel()
+
+	moment := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    any
+		expected time.Time
+		isErr    bool
+	}{
+		{name: "time", value: moment, expected: moment},
+		{name: "int64 unix seconds", value: moment.Unix(), expected: moment},
+		{name: "int unix seconds", value: int(moment.Unix()), expected: moment},
+		{name: "float64 unix seconds", value: float64(moment.Unix()) + 0.5, expected: moment.Add(500 * time.Millisecond)},
+		{name: "string unix seconds", value: "1704164645", expected: moment},
+		{name: "RFC3339", value: "2024-01-02T06:04:05+03:00", expected: moment},
+		{name: "RFC3339 with nanoseconds", value: "2024-01-02T03:04:05.123Z", expected: moment.Add(123 * time.Millisecond)},
+		{name: "time without zone is UTC", value: "2024-01-02T03:04:05", expected: moment},
+		{name: "date time", value: "2024-01-02 03:04:05", expected: moment},
+		{name: "date", value: "2024-01-02", expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid string", value: "yesterday", isErr: true},
+		{name: "unexpected type", value: true, isErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := toTimestamp(test.value)
+			if test.isErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Equal(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
@@ -5,27 +5,40 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/rabbitmq/amqp091-go"
 	publisher2 "github.com/txix-open/grmq/publisher"
 	"github.com/txix-open/isp-kit/grmqx"
-	"github.com/txix-open/isp-kit/json"
 	"github.com/txix-open/isp-kit/log"
 	"github.com/txix-open/isp-kit/retry"
 	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
 	"go.uber.org/ratelimit"
 )
 
 const (
-	maxRetryElapsedTime = 5 * time.Second
+	maxRetryElapsedTime  = 5 * time.Second
+	defaultConfirmWindow = 100
 )
 
+type metricStorage interface {
+	IncPublishRetry()
+	ObserveRateLimiterWait(duration time.Duration)
+}
+
 type publisher struct {
-	rmqCli  *grmqx.Client
-	rmqPub  *publisher2.Publisher
-	limiter ratelimit.Limiter
+	rmqCli         *grmqx.Client
+	confirmer      *confirmer
+	rmqPub         *publisher2.Publisher
+	messageBuilder messageBuilder
+	limiter        ratelimit.Limiter
+	metrics        metricStorage
 }
 
-func NewPublisher(ctx context.Context, cfg conf.Target, logger log.Logger) (publisher, error) {
+func NewPublisher(
+	ctx context.Context,
+	cfg conf.Target,
+	logger log.Logger,
+	metrics metricStorage,
+) (publisher, error) {
 	var rmqPub *publisher2.Publisher
 	if cfg.EnableMessageLogs {
 		rmqPub = cfg.Publisher.DefaultPublisher(grmqx.PublisherLog(logger, true))
@@ -33,6 +46,26 @@ func NewPublisher(ctx context.Context, cfg conf.Target, logger log.Logger) (publ
 		rmqPub = cfg.Publisher.DefaultPublisher()
 	}
 
+	if cfg.ConfirmMode || cfg.Mandatory {
+		window := cfg.ConfirmWindow
+		if window <= 0 {
+			window = defaultConfirmWindow
+		}
+		confirmer, err := newConfirmer(cfg.Client, window, cfg.Mandatory)
+		if err != nil {
+			return publisher{}, errors.WithMessage(err, "new confirmer")
+		}
+		rmqPub.SetRoundTripper(confirmer)
+
+		return publisher{
+			confirmer:      confirmer,
+			rmqPub:         rmqPub,
+			messageBuilder: newMessageBuilder(cfg.Message, cfg.Publisher.Exchange, cfg.Publisher.RoutingKey),
+			limiter:        ratelimit.New(cfg.Rps),
+			metrics:        metrics,
+		}, nil
+	}
+
 	rmqCli := grmqx.New(logger)
 	err := rmqCli.Upgrade(ctx, grmqx.NewConfig(
 		cfg.Client.Url(),
@@ -43,33 +76,54 @@ func NewPublisher(ctx context.Context, cfg conf.Target, logger log.Logger) (publ
 	}
 
 	return publisher{
-		rmqCli:  rmqCli,
-		rmqPub:  rmqPub,
-		limiter: ratelimit.New(cfg.Rps),
+		rmqCli:         rmqCli,
+		rmqPub:         rmqPub,
+		messageBuilder: newMessageBuilder(cfg.Message, cfg.Publisher.Exchange, cfg.Publisher.RoutingKey),
+		limiter:        ratelimit.New(cfg.Rps),
+		metrics:        metrics,
 	}, nil
 }
 
-func (p publisher) Publish(ctx context.Context, data any) error {
-	var err error
-	body, isPlainText := data.([]byte)
-	if !isPlainText {
-		body, err = json.Marshal(data)
-		if err != nil {
-			return errors.WithMessage(err, "marshal payload")
-		}
+func (p publisher) Publish(ctx context.Context, data any, metadata *domain.Metadata) error {
+	msg, err := p.messageBuilder.Build(data, metadata)
+	if err != nil {
+		return errors.WithMessage(err, "build message")
 	}
 
+	var (
+		returnedErr error
+		attempt     int
+	)
 	err = retry.NewExponentialBackoff(maxRetryElapsedTime).Do(ctx, func() error {
+		attempt++
+		if attempt > 1 {
+			p.metrics.IncPublishRetry()
+		}
+		start := time.Now()
 		_ = p.limiter.Take()
-		return p.rmqPub.Publish(ctx, &amqp091.Publishing{Body: body})
+		p.metrics.ObserveRateLimiterWait(time.Since(start))
+		err := p.rmqPub.PublishTo(ctx, msg.exchange, msg.routingKey, &msg.publishing)
+		if errors.Is(err, ErrReturned) {
+			returnedErr = err
+			return nil
+		}
+		return err
 	})
+	if returnedErr != nil {
+		return errors.WithMessagef(returnedErr, "publish message to '%s'", msg.routingKey)
+	}
 	if err != nil {
-		return errors.WithMessagef(err, "publish message to '%s'", p.rmqPub.RoutingKey)
+		return errors.WithMessagef(err, "publish message to '%s'", msg.routingKey)
 	}
 
 	return nil
 }
 
-func (p publisher) Close() {
+func (p publisher) Close() error {
+	if p.confirmer != nil {
+		p.confirmer.Close()
+		return nil
+	}
 	p.rmqCli.Close()
+	return nil
 }
@@ -0,0 +1,28 @@
+package script
+
+import (
+	"io"
+	"sync"
+
+	"github.com/txix-open/isp-kit/json"
+)
+
+// WriterConsole writes arguments of each console.log call as JSON array on a separate line.
+type WriterConsole struct {
+	writer io.Writer
+	lock   *sync.Mutex
+}
+
+func NewWriterConsole(writer io.Writer) WriterConsole {
+	return WriterConsole{
+		writer: writer,
+		lock:   new(sync.Mutex),
+	}
+}
+
+func (w WriterConsole) Log(args ...any) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_ = json.NewEncoder(w.writer).Encode(args)
+}
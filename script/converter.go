@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 	scripts "github.com/txix-open/isp-script"
+	"github.com/txix-open/mqpusher/domain"
 )
 
 const (
@@ -15,6 +16,7 @@ const (
 type converter struct {
 	engine     *scripts.Engine
 	execScript scripts.Script
+	console    scripts.Logger
 }
 
 func NewConverter(filePath string) (converter, error) {
@@ -31,15 +33,23 @@ func NewConverter(filePath string) (converter, error) {
 	return converter{
 		engine:     scripts.NewEngine(),
 		execScript: script,
+		console:    scripts.NewStdoutJsonLogger(),
 	}, nil
 }
 
-func (c converter) Convert(data any) (any, error) {
+// WithConsole sets logger receiving console.log calls of script.
+func (c converter) WithConsole(console scripts.Logger) converter {
+	c.console = console
+	return c
+}
+
+func (c converter) Convert(data any, metadata *domain.Metadata) (any, error) {
 	v, err := c.engine.Execute(c.execScript, data,
 		scripts.WithTimeout(scriptTimeout),
+		scripts.WithSet("metadata", metadata),
 		scripts.WithFieldNameMapper(jsonFieldNameMapper{}),
 		scripts.WithDefaultToolkit(),
-		scripts.WithLogger(scripts.NewStdoutJsonLogger()),
+		scripts.WithLogger(c.console),
 	)
 	if err != nil {
 		return nil, errors.WithMessage(err, "execute script")
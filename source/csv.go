@@ -5,92 +5,361 @@ import (
 	"encoding/csv"
 	"io"
 	"os"
+	"slices"
 	"sync/atomic"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
 	"github.com/txix-open/mqpusher/conf"
 	"github.com/txix-open/mqpusher/domain"
 	"github.com/txix-open/mqpusher/utils"
 )
 
+const (
+	failHeaderMode    = "fail"
+	mergeHeaderMode   = "merge"
+	reorderHeaderMode = "reorder"
+)
+
+// csvDataSource reads one or several csv files one by one.
+// Files may have different headers depending on header mode,
+// the columns of data are the columns of the first file or, in merge mode, the union of all columns.
 type csvDataSource struct {
-	csvReader *csv.Reader
-	closeFile func() error
+	files   []utils.DirFile
+	sep     rune
+	columns []string
+	schema  csvSchema
+	isStdin bool
 
-	readCounter   *atomic.Uint64
-	readerCounter utils.ReaderCounter
+	nextFileIdx   *atomic.Int64
+	startFileIdx  int
+	startOffset   int64
+	startRowNum   int64
+	currentReader *atomic.Pointer[csvFileReader]
 
-	columns  []string
-	fileSize float64
+	readCounter *atomic.Uint64
+	doneSize    *atomic.Uint64
+	filesSize   float64
 }
 
-func NewCsv(cfg conf.CsvDataSource) (csvDataSource, error) {
-	file, err := os.Open(cfg.FilePath)
+func NewCsv(cfg conf.CsvDataSource, checkpoint domain.Checkpoint) (csvDataSource, error) {
+	files, err := csvFiles(cfg)
 	if err != nil {
-		return csvDataSource{}, errors.WithMessagef(err, "open file '%s'", cfg.FilePath)
+		return csvDataSource{}, errors.WithMessage(err, "define csv files")
 	}
-	info, err := file.Stat()
-	if err != nil {
-		return csvDataSource{}, errors.WithMessage(err, "file stat")
+	if len(files) == 0 {
+		return csvDataSource{}, errors.Errorf("no csv files found by path '%s'", cfg.FilePath)
 	}
-	readerCounter := utils.NewReaderCounter(file)
 
-	csvReader := csv.NewReader(readerCounter)
 	sep, _ := utf8.DecodeRuneInString(cfg.Sep)
-	csvReader.Comma = sep
-	csvReader.ReuseRecord = true
-	csvReader.LazyQuotes = true
+	startFileIdx := min(checkpoint.FileIdx, len(files))
+	dataSource := csvDataSource{
+		files:         files,
+		sep:           sep,
+		schema:        newCsvSchema(cfg),
+		isStdin:       cfg.FilePath == utils.StdinPath,
+		nextFileIdx:   new(atomic.Int64),
+		startFileIdx:  startFileIdx,
+		startOffset:   checkpoint.Offset,
+		startRowNum:   checkpoint.RowNums[0],
+		currentReader: new(atomic.Pointer[csvFileReader]),
+		readCounter:   new(atomic.Uint64),
+		doneSize:      new(atomic.Uint64),
+	}
+	dataSource.nextFileIdx.Store(int64(startFileIdx))
+	for i, file := range files {
+		dataSource.filesSize += float64(file.Size)
+		if i < startFileIdx {
+			dataSource.doneSize.Add(uint64(file.Size)) // nolint:gosec
+		}
+	}
 
-	row, err := csvReader.Read()
+	if len(files) == 1 {
+		// single file, possibly stdin, is not scanned in advance
+		err = dataSource.openNextFile()
+		if err != nil {
+			return csvDataSource{}, err
+		}
+		if reader := dataSource.currentReader.Load(); reader != nil {
+			dataSource.columns = reader.columns
+		}
+		return dataSource, nil
+	}
+
+	dataSource.columns, err = scanCsvHeaders(files, sep, cfg.HeaderMode)
 	if err != nil {
-		return csvDataSource{}, errors.WithMessage(err, "read csv row")
+		return csvDataSource{}, errors.WithMessage(err, "scan csv headers")
 	}
-	columns := make([]string, len(row))
-	copy(columns, row)
+	return dataSource, nil
+}
 
-	return csvDataSource{
-		csvReader:     csvReader,
-		closeFile:     file.Close,
-		readCounter:   new(atomic.Uint64),
-		readerCounter: readerCounter,
-		columns:       columns,
-		fileSize:      float64(info.Size()),
-	}, nil
+func csvFiles(cfg conf.CsvDataSource) ([]utils.DirFile, error) {
+	if cfg.FilePath == utils.StdinPath {
+		return []utils.DirFile{{Path: cfg.FilePath, RelPath: cfg.FilePath}}, nil
+	}
+	if utils.IsGlob(cfg.FilePath) {
+		return utils.ListGlobFiles(cfg.FilePath, cfg.Directory.SortOrder) // nolint:wrapcheck
+	}
+
+	info, err := os.Stat(cfg.FilePath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "file '%s' stat", cfg.FilePath)
+	}
+	if !info.IsDir() {
+		return []utils.DirFile{{
+			Path:    cfg.FilePath,
+			RelPath: cfg.FilePath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}}, nil
+	}
+	return utils.ListDirFiles(cfg.FilePath, utils.ListOptions{ // nolint:wrapcheck
+		IsRecursive: cfg.Directory.IsRecursive,
+		Include:     cfg.Directory.Include,
+		Exclude:     cfg.Directory.Exclude,
+		SortOrder:   cfg.Directory.SortOrder,
+	})
 }
 
-func (c csvDataSource) GetData(_ context.Context) (*domain.Payload, error) {
-	v, err := c.csvReader.Read()
-	switch {
-	case errors.Is(err, io.EOF):
-		return nil, domain.ErrNoData
-	case err != nil:
-		return nil, errors.WithMessage(err, "csv reader read")
+// scanCsvHeaders checks headers of all files against the first one and returns data columns.
+func scanCsvHeaders(files []utils.DirFile, sep rune, headerMode string) ([]string, error) {
+	var columns []string
+	for i, file := range files {
+		reader, err := openCsvFile(file.Path, sep, 0)
+		if err != nil {
+			return nil, err
+		}
+		header := reader.columns
+		_ = reader.Close()
+		if i == 0 {
+			columns = header
+			continue
+		}
+
+		switch headerMode {
+		case mergeHeaderMode:
+			for _, column := range header {
+				if !slices.Contains(columns, column) {
+					columns = append(columns, column)
+				}
+			}
+		case reorderHeaderMode:
+			if !isSameColumns(columns, header) {
+				return nil, errors.Errorf("columns of file '%s' %v differ from columns of file '%s' %v",
+					file.Path, header, files[0].Path, columns)
+			}
+		default:
+			if !slices.Equal(columns, header) {
+				return nil, errors.Errorf("header of file '%s' %v differs from header of file '%s' %v",
+					file.Path, header, files[0].Path, columns)
+			}
+		}
+	}
+	return columns, nil
+}
+
+func isSameColumns(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func (c csvDataSource) openNextFile() error {
+	fileIdx := int(c.nextFileIdx.Load())
+	if fileIdx >= len(c.files) {
+		return nil
+	}
+
+	offset := int64(0)
+	rowNum := int64(0)
+	if fileIdx == c.startFileIdx && c.startOffset > 0 {
+		offset = c.startOffset
+		rowNum = c.startRowNum
+	}
+	reader, err := openCsvFile(c.files[fileIdx].Path, c.sep, offset)
+	if err != nil {
+		return err
+	}
+	reader.fileIdx = fileIdx
+	reader.rowNum = rowNum
+	c.currentReader.Store(reader)
+	c.nextFileIdx.Add(1)
+	return nil
+}
 
-	data := make(map[string]any)
-	for i, column := range c.columns {
-		data[column] = v[i]
+func (c csvDataSource) GetData(_ context.Context) (*domain.Payload, error) {
+	for {
+		reader := c.currentReader.Load()
+		if reader == nil {
+			if int(c.nextFileIdx.Load()) >= len(c.files) {
+				return nil, domain.ErrNoData
+			}
+			err := c.openNextFile()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		row, err := reader.csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			c.doneSize.Add(uint64(c.files[reader.fileIdx].Size)) // nolint:gosec
+			c.currentReader.Store(nil)
+			err = reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		reader.rowNum++
+		if err != nil {
+			return nil, errors.WithMessagef(err, "csv reader read row %d of file '%s'", reader.rowNum, reader.filePath)
+		}
+
+		c.readCounter.Add(1)
+		position := domain.FileRowPosition{
+			FileIdx: reader.fileIdx,
+			Offset:  reader.startOffset + reader.csvReader.InputOffset(),
+			RowNum:  reader.rowNum,
+		}
+		data, err := c.rowData(reader.columns, row)
+		if err != nil {
+			err = errors.WithMessagef(err, "row %d of file '%s'", reader.rowNum, reader.filePath)
+			return nil, domain.NewRecordError(err, rawCsvRow(reader.columns, row), position)
+		}
+
+		metadata := domain.NewMetadata()
+		metadata.SourceFile = reader.filePath
+		metadata.SourceRow = reader.rowNum
+		return &domain.Payload{
+			Data:     data,
+			Metadata: metadata,
+			Position: position,
+		}, nil
 	}
+}
 
-	c.readCounter.Add(1)
+func (c csvDataSource) rowData(columns []string, row []string) (map[string]any, error) {
+	data := make(map[string]any, len(c.columns))
+	for _, column := range c.columns {
+		data[column] = nil
+	}
+	for i, column := range columns {
+		if c.schema.isEmpty() {
+			data[column] = row[i]
+			continue
+		}
+		value, err := c.schema.convert(column, row[i])
+		if err != nil {
+			return nil, err
+		}
+		data[column] = value
+	}
+	return data, nil
+}
 
-	return &domain.Payload{Data: data}, nil
+func rawCsvRow(columns []string, row []string) []byte {
+	data := make(map[string]string, len(columns))
+	for i, column := range columns {
+		data[column] = row[i]
+	}
+	bytes, _ := json.Marshal(data)
+	return bytes
 }
 
 //nolint:mnd
 func (c csvDataSource) Progress() domain.Progress {
-	readDataPercent := float64(c.readerCounter.Count()) / c.fileSize * 100
-	return domain.Progress{
+	progress := domain.Progress{
 		ReadDataCount:   c.readCounter.Load(),
-		ReadDataPercent: &readDataPercent,
+		ReadDataPercent: nil,
+	}
+	if c.isStdin {
+		return progress
 	}
+
+	readSize := float64(c.doneSize.Load())
+	if reader := c.currentReader.Load(); reader != nil {
+		readSize += reader.inputFile.ReadSize()
+	}
+	readDataPercent := float64(100)
+	if c.filesSize > 0 {
+		readDataPercent = readSize / c.filesSize * 100
+	}
+	progress.ReadDataPercent = &readDataPercent
+	return progress
 }
 
 func (c csvDataSource) Close(_ context.Context) error {
-	err := c.closeFile()
+	reader := c.currentReader.Swap(nil)
+	if reader == nil {
+		return nil
+	}
+	return reader.Close()
+}
+
+type csvFileReader struct {
+	csvReader   *csv.Reader
+	inputFile   utils.InputFile
+	filePath    string
+	fileIdx     int
+	columns     []string
+	startOffset int64
+	rowNum      int64
+}
+
+// openCsvFile reads header of csv file and continues reading from offset if it is set.
+func openCsvFile(filePath string, sep rune, offset int64) (*csvFileReader, error) {
+	inputFile, err := utils.OpenInputFile(filePath, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "open input file")
+	}
+	csvReader := newCsvReader(inputFile, sep)
+
+	row, err := csvReader.Read()
+	if err != nil {
+		_ = inputFile.Close()
+		return nil, errors.WithMessagef(err, "read header of csv file '%s'", filePath)
+	}
+	columns := make([]string, len(row))
+	copy(columns, row)
+
+	startOffset := int64(0)
+	if offset > 0 {
+		_ = inputFile.Close()
+		inputFile, err = utils.OpenInputFile(filePath, offset)
+		if err != nil {
+			return nil, errors.WithMessage(err, "reopen input file")
+		}
+		startOffset = offset
+		csvReader = newCsvReader(inputFile, sep)
+	}
+
+	return &csvFileReader{
+		csvReader:   csvReader,
+		inputFile:   inputFile,
+		filePath:    filePath,
+		columns:     columns,
+		startOffset: startOffset,
+	}, nil
+}
+
+func newCsvReader(r io.Reader, sep rune) *csv.Reader {
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = sep
+	csvReader.ReuseRecord = true
+	csvReader.LazyQuotes = true
+	return csvReader
+}
+
+func (r *csvFileReader) Close() error {
+	err := r.inputFile.Close()
 	if err != nil {
-		return errors.WithMessage(err, "close file")
+		return errors.WithMessagef(err, "close input file '%s'", r.filePath)
 	}
 	return nil
 }
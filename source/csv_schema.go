@@ -0,0 +1,135 @@
+package source
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/conf"
+)
+
+const (
+	// maxSafeInteger is the max integer which is exactly represented by float64 of JSON consumers.
+	maxSafeInteger = 1 << 53
+)
+
+const (
+	stringColumnType    = "string"
+	intColumnType       = "int"
+	floatColumnType     = "float"
+	boolColumnType      = "bool"
+	jsonColumnType      = "json"
+	timestampColumnType = "timestamp"
+)
+
+// csvSchema converts csv cells to values of configured column types
+// or, if type inference is enabled, to the most specific type they can be parsed as.
+// Cells equal to null token are null in nullable columns and, if null token is set, in columns of inferred types.
+type csvSchema struct {
+	columns      map[string]conf.CsvColumn
+	nullToken    string
+	isInferNulls bool
+	isInferTypes bool
+}
+
+func newCsvSchema(cfg conf.CsvDataSource) csvSchema {
+	columns := make(map[string]conf.CsvColumn, len(cfg.Columns))
+	for _, column := range cfg.Columns {
+		columns[column.Name] = column
+	}
+	schema := csvSchema{
+		columns:      columns,
+		nullToken:    "",
+		isInferNulls: cfg.NullToken != nil,
+		isInferTypes: cfg.IsInferTypes,
+	}
+	if cfg.NullToken != nil {
+		schema.nullToken = *cfg.NullToken
+	}
+	return schema
+}
+
+func (s csvSchema) isEmpty() bool {
+	return len(s.columns) == 0 && !s.isInferTypes
+}
+
+func (s csvSchema) convert(columnName string, value string) (any, error) {
+	isNull := value == s.nullToken
+	column, ok := s.columns[columnName]
+	if !ok {
+		if !s.isInferTypes {
+			return value, nil
+		}
+		if isNull && s.isInferNulls {
+			return nil, nil
+		}
+		return inferValue(value), nil
+	}
+
+	if isNull && column.IsNullable {
+		return nil, nil
+	}
+	v, err := convertValue(column, value)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "convert value of column '%s' to %s", columnName, column.Type)
+	}
+	return v, nil
+}
+
+func convertValue(column conf.CsvColumn, value string) (any, error) {
+	switch column.Type {
+	case stringColumnType:
+		return value, nil
+	case intColumnType:
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64) // nolint:wrapcheck
+	case floatColumnType:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64) // nolint:wrapcheck
+	case boolColumnType:
+		return strconv.ParseBool(strings.TrimSpace(value)) // nolint:wrapcheck
+	case jsonColumnType:
+		var v any
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err // nolint:wrapcheck
+	case timestampColumnType:
+		layout := column.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, strings.TrimSpace(value)) // nolint:wrapcheck
+	default:
+		return value, nil
+	}
+}
+
+// inferValue converts value only if it is written exactly as the converted value would be,
+// so e.g. "007", "1.50", "TRUE" or integers beyond float64 precision remain strings.
+func inferValue(value string) any {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	intValue, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		isLossless := strconv.FormatInt(intValue, 10) == value &&
+			intValue >= -maxSafeInteger && intValue <= maxSafeInteger
+		if isLossless {
+			return intValue
+		}
+		return value
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err == nil && isNumeric(value) && strconv.FormatFloat(floatValue, 'f', -1, 64) == value {
+		return floatValue
+	}
+	return value
+}
+
+// isNumeric reports whether value is written as decimal number, so "NaN" or "Inf" are not.
+func isNumeric(value string) bool {
+	value = strings.TrimLeft(value, "+-")
+	return value != "" && strings.ContainsAny(value[:1], "0123456789.")
+}
@@ -0,0 +1,221 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/txix-open/mqpusher/conf"
+)
+
+func TestInferValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    string
+		expected any
+	}{
+		{value: "true", expected: true},
+		{value: "false", expected: false},
+		{value: "TRUE", expected: "TRUE"},
+		{value: "1", expected: int64(1)},
+		{value: "-42", expected: int64(-42)},
+		{value: "007", expected: "007"},
+		{value: "+1", expected: "+1"},
+		{value: "-0", expected: "-0"},
+		{value: "9007199254740992", expected: int64(9007199254740992)},
+		{value: "9007199254740993", expected: "9007199254740993"},
+		{value: "12345678901234567890", expected: "12345678901234567890"},
+		{value: "1.5", expected: 1.5},
+		{value: "-0.25", expected: -0.25},
+		{value: "1.50", expected: "1.50"},
+		{value: ".5", expected: ".5"},
+		{value: "1e3", expected: "1e3"},
+		{value: "NaN", expected: "NaN"},
+		{value: "Inf", expected: "Inf"},
+		{value: "", expected: ""},
+		{value: " 1", expected: " 1"},
+		{value: "abc", expected: "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Parallel()
+
+			result := inferValue(test.value)
+			if result != test.expected {
+				t.Fatalf("expected %#v, got %#v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestCsvSchemaConvert(t *testing.T) {
+	t.Parallel()
+
+	nullToken := "NULL"
+	columns := []conf.CsvColumn{
+		{Name: "id", Type: intColumnType},
+		{Name: "price", Type: floatColumnType, IsNullable: true},
+		{Name: "active", Type: boolColumnType},
+		{Name: "code", Type: stringColumnType},
+		{Name: "payload", Type: jsonColumnType},
+		{Name: "created", Type: timestampColumnType},
+		{Name: "day", Type: timestampColumnType, Layout: time.DateOnly},
+	}
+	tests := []struct {
+		name     string
+		cfg      conf.CsvDataSource
+		column   string
+		value    string
+		expected any
+		isErr    bool
+	}{
+		{
+			name:     "int",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "id",
+			value:    " 10 ",
+			expected: int64(10),
+		},
+		{
+			name:   "invalid int",
+			cfg:    conf.CsvDataSource{Columns: columns},
+			column: "id",
+			value:  "ten",
+			isErr:  true,
+		},
+		{
+			name:     "float",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "price",
+			value:    "1.50",
+			expected: 1.5,
+		},
+		{
+			name:     "empty value of nullable column without null token",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "price",
+			value:    "",
+			expected: nil,
+		},
+		{
+			name:     "null token of nullable column",
+			cfg:      conf.CsvDataSource{Columns: columns, NullToken: &nullToken},
+			column:   "price",
+			value:    "NULL",
+			expected: nil,
+		},
+		{
+			name:   "null token of not nullable column",
+			cfg:    conf.CsvDataSource{Columns: columns, NullToken: &nullToken},
+			column: "id",
+			value:  "NULL",
+			isErr:  true,
+		},
+		{
+			name:     "bool",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "active",
+			value:    "TRUE",
+			expected: true,
+		},
+		{
+			name:     "string column is not inferred",
+			cfg:      conf.CsvDataSource{Columns: columns, IsInferTypes: true},
+			column:   "code",
+			value:    "007",
+			expected: "007",
+		},
+		{
+			name:     "json",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "payload",
+			value:    `{"a":[1,"b"]}`,
+			expected: map[string]any{"a": []any{float64(1), "b"}},
+		},
+		{
+			name:     "timestamp with default layout",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "created",
+			value:    "2024-01-02T03:04:05Z",
+			expected: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name:     "timestamp with layout",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "day",
+			value:    "2024-01-02",
+			expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "column without type remains string",
+			cfg:      conf.CsvDataSource{Columns: columns},
+			column:   "other",
+			value:    "1",
+			expected: "1",
+		},
+		{
+			name:     "column without type is inferred",
+			cfg:      conf.CsvDataSource{Columns: columns, IsInferTypes: true},
+			column:   "other",
+			value:    "1",
+			expected: int64(1),
+		},
+		{
+			name:     "empty value is not inferred as null without null token",
+			cfg:      conf.CsvDataSource{IsInferTypes: true},
+			column:   "other",
+			value:    "",
+			expected: "",
+		},
+		{
+			name:     "null token is inferred as null",
+			cfg:      conf.CsvDataSource{IsInferTypes: true, NullToken: &nullToken},
+			column:   "other",
+			value:    "NULL",
+			expected: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := newCsvSchema(test.cfg).convert(test.column, test.value)
+			if test.isErr {
+				if err == nil {
+					t.Fatalf("expected error, got %#v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Fatalf("expected %#v, got %#v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestCsvSchemaIsEmpty(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cfg      conf.CsvDataSource
+		expected bool
+	}{
+		{name: "no columns", cfg: conf.CsvDataSource{}, expected: true},
+		{name: "columns", cfg: conf.CsvDataSource{Columns: []conf.CsvColumn{{Name: "id", Type: intColumnType}}}, expected: false},
+		{name: "inference", cfg: conf.CsvDataSource{IsInferTypes: true}, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if newCsvSchema(test.cfg).isEmpty() != test.expected {
+				t.Fatalf("expected isEmpty %t", test.expected)
+			}
+		})
+	}
+}
@@ -4,14 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"strings"
 	"sync/atomic"
 
-	"github.com/Masterminds/squirrel"
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/db/jsonb"
-	"github.com/txix-open/isp-kit/db/query"
-	"github.com/txix-open/isp-kit/dbrx"
 	"github.com/txix-open/isp-kit/json"
 	"github.com/txix-open/isp-kit/log"
 	"github.com/txix-open/mqpusher/conf"
@@ -20,29 +18,40 @@ import (
 )
 
 const (
-	viewName      = "__mqpusher_view"
-	viewIndexName = "__mqpusher_view_index"
-	viewRowNum    = "__mqpusher_view_row_num"
-	viewModRowNum = "__mqpusher_view_mod_row_num"
+	materializedViewReadStrategy = "materializedView"
+	keysetReadStrategy           = "keyset"
 )
 
+type dbReadStrategy interface {
+	prepare(ctx context.Context) (float64, error)
+	fetch(ctx context.Context, workerIdx int, dataChan chan<- *domain.Payload) error
+	cleanup(ctx context.Context) error
+}
+
 type dataBaseSource struct {
-	db       *dbrx.Client
+	db       *sql.DB
+	dbCloser io.Closer
 	logger   log.Logger
+	strategy dbReadStrategy
 	dataChan chan *domain.Payload
 	errChan  chan error
 
 	readCounter *atomic.Uint64
 	rowsCount   float64
-	cfg         conf.DbDataSource
+	parallel    int
 }
 
-func NewDataBase(ctx context.Context, cfg conf.DbDataSource, logger log.Logger) (dataBaseSource, error) {
-	db := dbrx.New(logger)
-	cfg.Client.MaxOpenConn = 64
-	err := db.Upgrade(ctx, cfg.Client)
+func NewDataBase(ctx context.Context, cfg conf.DbDataSource, logger log.Logger, checkpoint domain.Checkpoint) (dataBaseSource, error) {
+	if cfg.Query != "" && (len(cfg.SelectedColumns) > 0 || cfg.WhereClause != "") {
+		return dataBaseSource{}, errors.New("selectedColumns and whereClause are not supported with query, set them in query itself")
+	}
+	dialect, err := newDbDialect(cfg, logger)
+	if err != nil {
+		return dataBaseSource{}, errors.WithMessage(err, "define db dialect")
+	}
+	db, dbCloser, err := dialect.open(ctx)
 	if err != nil {
-		return dataBaseSource{}, errors.WithMessage(err, "upgrade db client")
+		return dataBaseSource{}, errors.WithMessagef(err, "open %s db", dialect.name())
 	}
 
 	for i, column := range cfg.SelectedColumns {
@@ -53,45 +62,35 @@ func NewDataBase(ctx context.Context, cfg conf.DbDataSource, logger log.Logger)
 	}
 	cfg.WhereClause, _ = strings.CutPrefix(cfg.WhereClause, "WHERE ")
 
+	var strategy dbReadStrategy
+	switch {
+	case cfg.Query != "":
+		strategy = newQueryStrategy(db, dialect, logger, cfg, checkpoint)
+	case cfg.ReadStrategy == keysetReadStrategy || cfg.ReadStrategy == "" && dialect.name() != postgresDialectName:
+		strategy = newKeysetStrategy(db, dialect, logger, cfg, checkpoint)
+	case dialect.name() != postgresDialectName:
+		_ = dbCloser.Close()
+		return dataBaseSource{}, errors.Errorf("read strategy '%s' is not supported by %s dialect", cfg.ReadStrategy, dialect.name())
+	default:
+		strategy = newMaterializedViewStrategy(db, dialect, logger, cfg, checkpoint)
+	}
+
 	dataSource := dataBaseSource{
 		db:          db,
+		dbCloser:    dbCloser,
 		logger:      logger,
+		strategy:    strategy,
 		dataChan:    make(chan *domain.Payload, cfg.BatchSize*uint64(cfg.Parallel)), // nolint:gosec
 		errChan:     make(chan error, 1),
 		readCounter: new(atomic.Uint64),
-		cfg:         cfg,
-	}
-
-	fields := append([]string{
-		fmt.Sprintf("ROW_NUMBER() OVER () AS %s", viewRowNum),
-		fmt.Sprintf("MOD(ROW_NUMBER() OVER (), %d) AS %s", cfg.Parallel, viewModRowNum)},
-		cfg.PrimaryKey...,
-	)
-	q, _, err := query.New().
-		Select(fields...).
-		From(cfg.Table).
-		Where(cfg.WhereClause).
-		ToSql()
-	if err != nil {
-		return dataBaseSource{}, errors.WithMessage(err, "build select query")
-	}
-	err = dataSource.createMaterializedView(ctx, viewName, q)
-	if err != nil {
-		return dataBaseSource{}, errors.WithMessage(err, "create materialized view")
-	}
-
-	err = dataSource.createViewIndex(ctx)
-	if err != nil {
-		return dataBaseSource{}, errors.WithMessage(err, "create view index")
+		parallel:    cfg.Parallel,
 	}
 
-	q = fmt.Sprintf("SELECT COUNT(1) FROM %s", viewName)
-	logger.Info(ctx, "selecting rows count", log.String("query", q))
-	err = db.SelectRow(ctx, &dataSource.rowsCount, q)
+	dataSource.rowsCount, err = strategy.prepare(ctx)
 	if err != nil {
-		return dataBaseSource{}, errors.WithMessage(err, "select view rows count")
+		_ = dbCloser.Close()
+		return dataBaseSource{}, errors.WithMessage(err, "prepare read strategy")
 	}
-	logger.Info(ctx, fmt.Sprintf("rows count of %s: %d", viewName, int(dataSource.rowsCount)))
 
 	go dataSource.startFetchingData(ctx)
 
@@ -109,14 +108,12 @@ func (d dataBaseSource) Progress() domain.Progress {
 }
 
 func (d dataBaseSource) Close(ctx context.Context) error {
-	query := fmt.Sprintf("DROP MATERIALIZED VIEW %s CASCADE", viewName)
-	d.logger.Info(ctx, "dropping materialized view", log.String("query", query))
-	_, err := d.db.Exec(ctx, query)
+	err := d.strategy.cleanup(ctx)
 	if err != nil {
-		d.logger.Error(ctx, errors.WithMessage(err, "drop materialized view"))
+		d.logger.Error(ctx, errors.WithMessage(err, "cleanup read strategy"))
 	}
 
-	err = d.db.Close()
+	err = d.dbCloser.Close()
 	if err != nil {
 		return errors.WithMessage(err, "close db conn")
 	}
@@ -124,8 +121,10 @@ func (d dataBaseSource) Close(ctx context.Context) error {
 	return nil
 }
 
-func (d dataBaseSource) GetData(_ context.Context) (*domain.Payload, error) {
+func (d dataBaseSource) GetData(ctx context.Context) (*domain.Payload, error) {
 	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case v, ok := <-d.dataChan:
 		if !ok {
 			return nil, domain.ErrNoData
@@ -141,8 +140,8 @@ func (d dataBaseSource) startFetchingData(ctx context.Context) {
 	defer close(d.dataChan)
 
 	g, ctx := errgroup.WithContext(ctx)
-	for i := range d.cfg.Parallel {
-		g.Go(func() error { return d.getData(ctx, i) })
+	for i := range d.parallel {
+		g.Go(func() error { return d.strategy.fetch(ctx, i, d.dataChan) })
 	}
 
 	err := g.Wait()
@@ -151,72 +150,29 @@ func (d dataBaseSource) startFetchingData(ctx context.Context) {
 	}
 }
 
-func (d dataBaseSource) getData(ctx context.Context, workerIdx int) error {
-	cli, err := d.db.DB()
-	if err != nil {
-		return errors.WithMessage(err, "db cli")
-	}
-
-	columns := append([]string{viewRowNum}, d.cfg.SelectedColumns...)
-	joinClause := fmt.Sprintf("%s USING (%s)", viewName, strings.Join(d.cfg.PrimaryKey, ","))
-	builder := query.New().
-		Select(columns...).
-		From(d.cfg.Table).
-		InnerJoin(joinClause)
+const jsonbColumnType = "JSONB"
 
-	maxRowNum := int64(0)
-	handleViewRowIdx := func(rowNum any) error {
-		v, ok := (rowNum).(int64)
-		if !ok {
-			return errors.Errorf("cast '%s' field to int", viewRowNum)
-		}
-		maxRowNum = max(maxRowNum, v)
+func scanRows(ctx context.Context, logger log.Logger, dialect dbDialect, rows *sql.Rows) ([]map[string]any, error) {
+	result := make([]map[string]any, 0)
+	err := eachRow(ctx, logger, dialect, rows, func(data map[string]any) error {
+		result = append(result, data)
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	for {
-		q, args, err := builder.Where(squirrel.And{
-			squirrel.Eq{viewModRowNum: workerIdx},
-			squirrel.Gt{viewRowNum: maxRowNum},
-		}).OrderBy(viewRowNum).
-			Limit(d.cfg.BatchSize).
-			ToSql()
-		if err != nil {
-			return errors.WithMessagef(err, "build select query to '%s' table", d.cfg.Table)
-		}
-
-		rows, err := cli.QueryContext(ctx, q, args...)
-		if err != nil {
-			return errors.WithMessage(err, "query context")
-		}
-
-		dataList, err := d.handleRows(ctx, rows, handleViewRowIdx)
-		if err != nil {
-			return errors.WithMessage(err, "handle rows")
-		}
-		if len(dataList) == 0 {
-			return nil
-		}
-
-		for _, data := range dataList {
-			d.dataChan <- &domain.Payload{Data: data}
-		}
-	}
+	return result, nil
 }
 
-type rowNumHandlerFunc func(rowNum any) error
-
-const jsonbColumnType = "JSONB"
-
-func (d dataBaseSource) handleRows(ctx context.Context, rows *sql.Rows, handleRowNum rowNumHandlerFunc) ([]map[string]any, error) {
+func eachRow(ctx context.Context, logger log.Logger, dialect dbDialect, rows *sql.Rows, handle func(data map[string]any) error) error {
 	defer func() {
 		err := rows.Close()
 		if err != nil {
-			d.logger.Warn(ctx, errors.WithMessage(err, "close rows"))
+			logger.Warn(ctx, errors.WithMessage(err, "close rows"))
 		}
 	}()
 
 	var (
-		result  = make([]map[string]any, 0)
 		columns []*sql.ColumnType
 		err     error
 	)
@@ -224,7 +180,7 @@ func (d dataBaseSource) handleRows(ctx context.Context, rows *sql.Rows, handleRo
 		if columns == nil {
 			columns, err = rows.ColumnTypes()
 			if err != nil {
-				return nil, errors.WithMessage(err, "get column types")
+				return errors.WithMessage(err, "get column types")
 			}
 		}
 
@@ -234,45 +190,28 @@ func (d dataBaseSource) handleRows(ctx context.Context, rows *sql.Rows, handleRo
 		}
 		err := rows.Scan(values...)
 		if err != nil {
-			return nil, errors.WithMessage(err, "scan row values")
+			return errors.WithMessage(err, "scan row values")
 		}
 
-		data, err := d.buildColumnsMap(columns, values, handleRowNum)
+		data, err := buildColumnsMap(dialect, columns, values)
 		if err != nil {
-			return nil, errors.WithMessage(err, "build map from columns")
+			return errors.WithMessage(err, "build map from columns")
+		}
+		err = handle(data)
+		if err != nil {
+			return err
 		}
-		result = append(result, data)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, errors.WithMessage(err, "rows error")
-	}
-
-	return result, nil
-}
-
-func (d dataBaseSource) createMaterializedView(ctx context.Context, viewName string, query string) error {
-	query = fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s", viewName, query)
-	d.logger.Info(ctx, "creating materialized view", log.String("query", query))
-	_, err := d.db.Exec(ctx, query)
-	if err != nil {
-		return errors.WithMessagef(err, "exec create materialized view '%s' query", viewName)
+		return errors.WithMessage(err, "rows error")
 	}
-	return nil
-}
 
-func (d dataBaseSource) createViewIndex(ctx context.Context) error {
-	query := fmt.Sprintf("CREATE INDEX %s ON %s (%s, %s)", viewIndexName, viewName, viewRowNum, viewModRowNum)
-	d.logger.Info(ctx, "creating index", log.String("query", query))
-	_, err := d.db.Exec(ctx, query)
-	if err != nil {
-		return errors.WithMessagef(err, "exec create index '%s' query", viewIndexName)
-	}
 	return nil
 }
 
-func (d dataBaseSource) buildColumnsMap(columns []*sql.ColumnType, values []any, handleRowNum rowNumHandlerFunc) (map[string]any, error) {
+func buildColumnsMap(dialect dbDialect, columns []*sql.ColumnType, values []any) (map[string]any, error) {
 	result := make(map[string]any, len(values))
 	for i, column := range columns {
 		columnName := column.Name()
@@ -280,50 +219,41 @@ func (d dataBaseSource) buildColumnsMap(columns []*sql.ColumnType, values []any,
 		if !ok {
 			return nil, errors.Errorf("cast value to pointer; column = %s", columnName)
 		}
-
-		if column.DatabaseTypeName() == jsonbColumnType {
-			v, err := d.handleJsonbType(*v)
-			if err != nil {
-				return nil, errors.WithMessagef(err, "handle jsonb type; column = %s", columnName)
-			}
-
-			result[columnName] = v
-			continue
-		}
-
-		if columnName != viewRowNum {
-			result[columnName] = *v
+		if *v == nil {
+			result[columnName] = nil
 			continue
 		}
 
-		if handleRowNum == nil {
-			continue
-		}
-		err := handleRowNum(*v)
+		value, err := dialect.columnValue(column, *v)
 		if err != nil {
-			return nil, errors.WithMessage(err, "handle view row index")
+			return nil, errors.WithMessagef(err, "map %s value; column = %s", column.DatabaseTypeName(), columnName)
 		}
+		result[columnName] = value
 	}
 
 	return result, nil
 }
 
-func (d dataBaseSource) handleJsonbType(v any) (any, error) {
+func handleJsonbType(v any) (any, error) {
 	bytes, ok := (v).(jsonb.Type)
 	if !ok {
 		return nil, errors.New("cast value to jsonb pointer")
 	}
+	return decodeJsonColumn(bytes)
+}
 
+// decodeJsonColumn decodes JSON object or array stored in column.
+func decodeJsonColumn(bytes []byte) (any, error) {
 	var result map[string]any
 	err := json.Unmarshal(bytes, &result)
 	if err != nil {
-		return d.handleArray(bytes)
+		return handleArray(bytes)
 	}
 
 	return result, nil
 }
 
-func (d dataBaseSource) handleArray(bytes []byte) (any, error) {
+func handleArray(bytes []byte) (any, error) {
 	result := make([]any, 0)
 	err := json.Unmarshal(bytes, &result)
 	if err != nil {
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/dbrx"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/validator"
+	"github.com/txix-open/mqpusher/conf"
+)
+
+const (
+	postgresDialectName = "postgres"
+	mysqlDialectName    = "mysql"
+	sqliteDialectName   = "sqlite"
+)
+
+const (
+	maxOpenConnections = 64
+)
+
+// dbDialect hides differences of SQL databases from read strategies:
+// connection, query syntax and mapping of column values.
+type dbDialect interface {
+	name() string
+	open(ctx context.Context) (*sql.DB, io.Closer, error)
+	statementBuilder() squirrel.StatementBuilderType
+	// bindVar returns placeholder of query argument with 1-based index,
+	// isPositionalBindVar reports whether the argument must be passed for every placeholder.
+	bindVar(idx int) string
+	isPositionalBindVar() bool
+	offsetClause(offset int64) string
+	partitionCondition(column string, parallel int, workerIdx int) (string, error)
+	columnValue(column *sql.ColumnType, value any) (any, error)
+}
+
+// nolint:ireturn
+func newDbDialect(cfg conf.DbDataSource, logger log.Logger) (dbDialect, error) {
+	switch cfg.Dialect {
+	case "", postgresDialectName:
+		return postgresDialect{cfg: cfg, logger: logger}, nil
+	case mysqlDialectName:
+		return mysqlDialect{cfg: cfg}, nil
+	case sqliteDialectName:
+		return sqliteDialect{cfg: cfg}, nil
+	default:
+		return nil, errors.Errorf("unsupported db dialect '%s'", cfg.Dialect)
+	}
+}
+
+type postgresDialect struct {
+	cfg    conf.DbDataSource
+	logger log.Logger
+}
+
+func (d postgresDialect) name() string {
+	return postgresDialectName
+}
+
+func (d postgresDialect) open(ctx context.Context) (*sql.DB, io.Closer, error) {
+	err := validator.Default.ValidateToError(d.cfg.Client)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "validate db client config")
+	}
+
+	db := dbrx.New(d.logger)
+	d.cfg.Client.MaxOpenConn = maxOpenConnections
+	err = db.Upgrade(ctx, d.cfg.Client)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "upgrade db client")
+	}
+	cli, err := db.DB()
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "db cli")
+	}
+	return cli.DB.DB, db, nil
+}
+
+func (d postgresDialect) statementBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+}
+
+func (d postgresDialect) bindVar(idx int) string {
+	return fmt.Sprintf("$%d", idx)
+}
+
+func (d postgresDialect) isPositionalBindVar() bool {
+	return false
+}
+
+func (d postgresDialect) offsetClause(offset int64) string {
+	return fmt.Sprintf("OFFSET %d", offset)
+}
+
+func (d postgresDialect) partitionCondition(column string, parallel int, workerIdx int) (string, error) {
+	// sign bit is cleared instead of ABS, which overflows for minimal integer
+	return fmt.Sprintf("(HASHTEXT(%s::TEXT) & 2147483647) %% %d = %d", column, parallel, workerIdx), nil
+}
+
+func (d postgresDialect) columnValue(column *sql.ColumnType, value any) (any, error) {
+	if column.DatabaseTypeName() != jsonbColumnType {
+		return value, nil
+	}
+	v, err := handleJsonbType(value)
+	if err != nil {
+		return nil, errors.WithMessage(err, "handle jsonb type")
+	}
+	return v, nil
+}
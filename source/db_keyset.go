@@ -0,0 +1,368 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+const (
+	nullKeyType   = "null"
+	intKeyType    = "int"
+	uintKeyType   = "uint"
+	floatKeyType  = "float"
+	boolKeyType   = "bool"
+	stringKeyType = "string"
+	bytesKeyType  = "bytes"
+	timeKeyType   = "time"
+)
+
+const (
+	keyColumnPrefix = "__mqpusher_key_"
+	keyTileColumn   = "__mqpusher_key_tile"
+	keyTileRowNum   = "__mqpusher_key_tile_row_num"
+)
+
+type keyRange struct {
+	lower []any
+	upper []any
+}
+
+type keysetStrategy struct {
+	db         *sql.DB
+	dialect    dbDialect
+	logger     log.Logger
+	cfg        conf.DbDataSource
+	checkpoint domain.Checkpoint
+
+	keyColumns    []string
+	keyAliases    []string
+	ranges        *[]keyRange
+	encodedRanges *[]domain.KeyRange
+}
+
+func newKeysetStrategy(
+	db *sql.DB,
+	dialect dbDialect,
+	logger log.Logger,
+	cfg conf.DbDataSource,
+	checkpoint domain.Checkpoint,
+) keysetStrategy {
+	keyColumns := make([]string, len(cfg.PrimaryKey))
+	keyAliases := make([]string, len(cfg.PrimaryKey))
+	for i, column := range cfg.PrimaryKey {
+		keyColumns[i] = fmt.Sprintf("%s.%s", cfg.Table, column)
+		keyAliases[i] = fmt.Sprintf("%s%d", keyColumnPrefix, i)
+	}
+	return keysetStrategy{
+		db:            db,
+		dialect:       dialect,
+		logger:        logger,
+		cfg:           cfg,
+		checkpoint:    checkpoint,
+		keyColumns:    keyColumns,
+		keyAliases:    keyAliases,
+		ranges:        new([]keyRange),
+		encodedRanges: new([]domain.KeyRange),
+	}
+}
+
+func (s keysetStrategy) prepare(ctx context.Context) (float64, error) {
+	q, _, err := s.dialect.statementBuilder().
+		Select("COUNT(1)").
+		From(s.cfg.Table).
+		Where(s.cfg.WhereClause).
+		ToSql()
+	if err != nil {
+		return 0, errors.WithMessage(err, "build count query")
+	}
+	var rowsCount float64
+	s.logger.Info(ctx, "selecting rows count", log.String("query", q))
+	err = s.db.QueryRowContext(ctx, q).Scan(&rowsCount)
+	if err != nil {
+		return 0, errors.WithMessage(err, "select rows count")
+	}
+	s.logger.Info(ctx, fmt.Sprintf("rows count of %s: %d", s.cfg.Table, int(rowsCount)))
+
+	ranges, err := s.defineRanges(ctx)
+	if err != nil {
+		return 0, err
+	}
+	*s.ranges = ranges
+	*s.encodedRanges = make([]domain.KeyRange, len(ranges))
+	for i, keyRange := range ranges {
+		(*s.encodedRanges)[i] = domain.KeyRange{Lower: encodeKey(keyRange.lower), Upper: encodeKey(keyRange.upper)}
+	}
+
+	return rowsCount, nil
+}
+
+// defineRanges splits table into ranges of keys by parallel count
+// or restores ranges from checkpoint, as saved keys are positions within them.
+func (s keysetStrategy) defineRanges(ctx context.Context) ([]keyRange, error) {
+	if len(s.checkpoint.Keys) > 0 {
+		return s.checkpointRanges()
+	}
+
+	if s.cfg.Parallel == 1 {
+		return []keyRange{{lower: nil, upper: nil}}, nil
+	}
+
+	bounds, err := s.selectBounds(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "select key bounds")
+	}
+	ranges := make([]keyRange, 0, len(bounds)+1)
+	var lower []any
+	for i, upper := range bounds {
+		if i == len(bounds)-1 {
+			upper = nil
+		}
+		ranges = append(ranges, keyRange{lower: lower, upper: upper})
+		lower = upper
+	}
+	if len(ranges) == 0 {
+		ranges = append(ranges, keyRange{lower: nil, upper: nil})
+	}
+	return ranges, nil
+}
+
+func (s keysetStrategy) checkpointRanges() ([]keyRange, error) {
+	if s.checkpoint.Parallel != s.cfg.Parallel {
+		return nil, errors.Errorf("checkpoint is saved with parallel %d, resume it with the same parallel instead of %d",
+			s.checkpoint.Parallel, s.cfg.Parallel)
+	}
+	if len(s.checkpoint.KeyRanges) == 0 {
+		return nil, errors.New("checkpoint has no key ranges")
+	}
+
+	ranges := make([]keyRange, len(s.checkpoint.KeyRanges))
+	for i, saved := range s.checkpoint.KeyRanges {
+		lower, err := decodeKey(saved.Lower)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode lower bound of key range %d", i)
+		}
+		upper, err := decodeKey(saved.Upper)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode upper bound of key range %d", i)
+		}
+		ranges[i] = keyRange{lower: lower, upper: upper}
+	}
+	return ranges, nil
+}
+
+func (s keysetStrategy) selectBounds(ctx context.Context) ([][]any, error) {
+	orderBy := strings.Join(s.keyColumns, ",")
+	selected := make([]string, len(s.keyColumns))
+	descOrder := make([]string, len(s.keyColumns))
+	for i, column := range s.keyColumns {
+		selected[i] = fmt.Sprintf("%s AS %s", column, s.keyAliases[i])
+		descOrder[i] = fmt.Sprintf("%s DESC", s.keyAliases[i])
+	}
+	tiles, _, err := s.dialect.statementBuilder().
+		Select(append(selected, fmt.Sprintf("NTILE(%d) OVER (ORDER BY %s) AS %s", s.cfg.Parallel, orderBy, keyTileColumn))...).
+		From(s.cfg.Table).
+		Where(s.cfg.WhereClause).
+		ToSql()
+	if err != nil {
+		return nil, errors.WithMessage(err, "build tiles query")
+	}
+	// the last key of every tile is selected by window function, which unlike DISTINCT ON is supported by all dialects
+	lastKeys := fmt.Sprintf("SELECT %s, %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS %s FROM (%s) AS tiles",
+		strings.Join(s.keyAliases, ","), keyTileColumn, keyTileColumn, strings.Join(descOrder, ","), keyTileRowNum, tiles)
+	q := fmt.Sprintf("SELECT %s FROM (%s) AS last_keys WHERE %s = 1 ORDER BY %s",
+		strings.Join(s.keyAliases, ","), lastKeys, keyTileRowNum, keyTileColumn)
+
+	s.logger.Info(ctx, "selecting key bounds", log.String("query", q))
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, errors.WithMessage(err, "query context")
+	}
+	dataList, err := scanRows(ctx, s.logger, s.dialect, rows)
+	if err != nil {
+		return nil, errors.WithMessage(err, "handle rows")
+	}
+
+	bounds := make([][]any, 0, len(dataList))
+	for _, data := range dataList {
+		bounds = append(bounds, s.extractKey(data))
+	}
+	return bounds, nil
+}
+
+func (s keysetStrategy) cleanup(_ context.Context) error { return nil }
+
+func (s keysetStrategy) fetch(ctx context.Context, workerIdx int, dataChan chan<- *domain.Payload) error {
+	ranges := *s.ranges
+	if workerIdx >= len(ranges) {
+		return nil
+	}
+	keyRange := ranges[workerIdx]
+
+	selected := make([]string, len(s.keyColumns))
+	for i, column := range s.keyColumns {
+		selected[i] = fmt.Sprintf("%s AS %s", column, s.keyAliases[i])
+	}
+	builder := s.dialect.statementBuilder().
+		Select(append(selected, s.cfg.SelectedColumns...)...).
+		From(s.cfg.Table).
+		Where(s.cfg.WhereClause).
+		OrderBy(s.keyColumns...).
+		Limit(s.cfg.BatchSize)
+	if keyRange.upper != nil {
+		builder = builder.Where(s.compareKey("<=", keyRange.upper))
+	}
+
+	lastKey := keyRange.lower
+	savedKey, ok := s.checkpoint.Keys[workerIdx]
+	if ok {
+		key, err := decodeKey(savedKey)
+		if err != nil {
+			return errors.WithMessagef(err, "decode checkpoint key of worker %d", workerIdx)
+		}
+		lastKey = key
+	}
+	for {
+		pageBuilder := builder
+		if lastKey != nil {
+			pageBuilder = pageBuilder.Where(s.compareKey(">", lastKey))
+		}
+		q, args, err := pageBuilder.ToSql()
+		if err != nil {
+			return errors.WithMessagef(err, "build select query to '%s' table", s.cfg.Table)
+		}
+
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return errors.WithMessage(err, "query context")
+		}
+
+		dataList, err := scanRows(ctx, s.logger, s.dialect, rows)
+		if err != nil {
+			return errors.WithMessage(err, "handle rows")
+		}
+
+		for _, data := range dataList {
+			lastKey = s.extractKey(data)
+			select {
+			case dataChan <- &domain.Payload{
+				Data: data,
+				Position: domain.KeyPosition{
+					WorkerIdx: workerIdx,
+					Key:       encodeKey(lastKey),
+					KeyRanges: *s.encodedRanges,
+					Parallel:  s.cfg.Parallel,
+				},
+			}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if uint64(len(dataList)) < s.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s keysetStrategy) compareKey(operator string, key []any) squirrel.Sqlizer {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(key)), ",")
+	return squirrel.Expr(
+		fmt.Sprintf("(%s) %s (%s)", strings.Join(s.keyColumns, ","), operator, placeholders),
+		key...,
+	)
+}
+
+func (s keysetStrategy) extractKey(data map[string]any) []any {
+	key := make([]any, len(s.keyAliases))
+	for i, alias := range s.keyAliases {
+		key[i] = data[alias]
+		delete(data, alias)
+	}
+	return key
+}
+
+// nolint:cyclop
+func encodeKey(key []any) []domain.KeyValue {
+	if key == nil {
+		return nil
+	}
+	result := make([]domain.KeyValue, len(key))
+	for i, v := range key {
+		switch v := v.(type) {
+		case nil:
+			result[i] = domain.KeyValue{Type: nullKeyType}
+		case int64:
+			result[i] = domain.KeyValue{Type: intKeyType, Value: strconv.FormatInt(v, 10)}
+		case int32:
+			result[i] = domain.KeyValue{Type: intKeyType, Value: strconv.FormatInt(int64(v), 10)}
+		case int16:
+			result[i] = domain.KeyValue{Type: intKeyType, Value: strconv.FormatInt(int64(v), 10)}
+		case int:
+			result[i] = domain.KeyValue{Type: intKeyType, Value: strconv.Itoa(v)}
+		case uint64:
+			result[i] = domain.KeyValue{Type: uintKeyType, Value: strconv.FormatUint(v, 10)}
+		case float64:
+			result[i] = domain.KeyValue{Type: floatKeyType, Value: strconv.FormatFloat(v, 'g', -1, 64)}
+		case float32:
+			result[i] = domain.KeyValue{Type: floatKeyType, Value: strconv.FormatFloat(float64(v), 'g', -1, 32)}
+		case bool:
+			result[i] = domain.KeyValue{Type: boolKeyType, Value: strconv.FormatBool(v)}
+		case []byte:
+			result[i] = domain.KeyValue{Type: bytesKeyType, Value: base64.StdEncoding.EncodeToString(v)}
+		case time.Time:
+			result[i] = domain.KeyValue{Type: timeKeyType, Value: v.Format(time.RFC3339Nano)}
+		case string:
+			result[i] = domain.KeyValue{Type: stringKeyType, Value: v}
+		default:
+			result[i] = domain.KeyValue{Type: stringKeyType, Value: fmt.Sprint(v)}
+		}
+	}
+	return result
+}
+
+func decodeKey(key []domain.KeyValue) ([]any, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	result := make([]any, len(key))
+	for i, v := range key {
+		var (
+			value any
+			err   error
+		)
+		switch v.Type {
+		case nullKeyType:
+		case intKeyType:
+			value, err = strconv.ParseInt(v.Value, 10, 64)
+		case uintKeyType:
+			value, err = strconv.ParseUint(v.Value, 10, 64)
+		case floatKeyType:
+			value, err = strconv.ParseFloat(v.Value, 64)
+		case boolKeyType:
+			value, err = strconv.ParseBool(v.Value)
+		case bytesKeyType:
+			value, err = base64.StdEncoding.DecodeString(v.Value)
+		case timeKeyType:
+			value, err = time.Parse(time.RFC3339Nano, v.Value)
+		case stringKeyType:
+			value = v.Value
+		default:
+			return nil, errors.Errorf("unsupported type '%s' of key value", v.Type)
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse %s key value '%s'", v.Type, v.Value)
+		}
+		result[i] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,165 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+const (
+	viewName      = "__mqpusher_view"
+	viewIndexName = "__mqpusher_view_index"
+	viewRowNum    = "__mqpusher_view_row_num"
+	viewModRowNum = "__mqpusher_view_mod_row_num"
+)
+
+type materializedViewStrategy struct {
+	db         *sql.DB
+	dialect    dbDialect
+	logger     log.Logger
+	cfg        conf.DbDataSource
+	checkpoint domain.Checkpoint
+}
+
+func newMaterializedViewStrategy(
+	db *sql.DB,
+	dialect dbDialect,
+	logger log.Logger,
+	cfg conf.DbDataSource,
+	checkpoint domain.Checkpoint,
+) materializedViewStrategy {
+	return materializedViewStrategy{
+		db:         db,
+		dialect:    dialect,
+		logger:     logger,
+		cfg:        cfg,
+		checkpoint: checkpoint,
+	}
+}
+
+func (s materializedViewStrategy) prepare(ctx context.Context) (float64, error) {
+	orderBy := strings.Join(s.cfg.PrimaryKey, ",")
+	fields := append([]string{
+		fmt.Sprintf("ROW_NUMBER() OVER (ORDER BY %s) AS %s", orderBy, viewRowNum),
+		fmt.Sprintf("MOD(ROW_NUMBER() OVER (ORDER BY %s), %d) AS %s", orderBy, s.cfg.Parallel, viewModRowNum)},
+		s.cfg.PrimaryKey...,
+	)
+	q, _, err := s.dialect.statementBuilder().
+		Select(fields...).
+		From(s.cfg.Table).
+		Where(s.cfg.WhereClause).
+		ToSql()
+	if err != nil {
+		return 0, errors.WithMessage(err, "build select query")
+	}
+	err = s.createMaterializedView(ctx, viewName, q)
+	if err != nil {
+		return 0, errors.WithMessage(err, "create materialized view")
+	}
+
+	err = s.createViewIndex(ctx)
+	if err != nil {
+		return 0, errors.WithMessage(err, "create view index")
+	}
+
+	var rowsCount float64
+	q = fmt.Sprintf("SELECT COUNT(1) FROM %s", viewName)
+	s.logger.Info(ctx, "selecting rows count", log.String("query", q))
+	err = s.db.QueryRowContext(ctx, q).Scan(&rowsCount)
+	if err != nil {
+		return 0, errors.WithMessage(err, "select view rows count")
+	}
+	s.logger.Info(ctx, fmt.Sprintf("rows count of %s: %d", viewName, int(rowsCount)))
+
+	return rowsCount, nil
+}
+
+func (s materializedViewStrategy) cleanup(ctx context.Context) error {
+	query := fmt.Sprintf("DROP MATERIALIZED VIEW %s CASCADE", viewName)
+	s.logger.Info(ctx, "dropping materialized view", log.String("query", query))
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.WithMessage(err, "drop materialized view")
+	}
+	return nil
+}
+
+func (s materializedViewStrategy) fetch(ctx context.Context, workerIdx int, dataChan chan<- *domain.Payload) error {
+	columns := append([]string{viewRowNum}, s.cfg.SelectedColumns...)
+	joinClause := fmt.Sprintf("%s USING (%s)", viewName, strings.Join(s.cfg.PrimaryKey, ","))
+	builder := s.dialect.statementBuilder().
+		Select(columns...).
+		From(s.cfg.Table).
+		InnerJoin(joinClause)
+
+	maxRowNum := s.checkpoint.RowNums[workerIdx]
+	for {
+		q, args, err := builder.Where(squirrel.And{
+			squirrel.Eq{viewModRowNum: workerIdx},
+			squirrel.Gt{viewRowNum: maxRowNum},
+		}).OrderBy(viewRowNum).
+			Limit(s.cfg.BatchSize).
+			ToSql()
+		if err != nil {
+			return errors.WithMessagef(err, "build select query to '%s' table", s.cfg.Table)
+		}
+
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return errors.WithMessage(err, "query context")
+		}
+
+		dataList, err := scanRows(ctx, s.logger, s.dialect, rows)
+		if err != nil {
+			return errors.WithMessage(err, "handle rows")
+		}
+		if len(dataList) == 0 {
+			return nil
+		}
+
+		for _, data := range dataList {
+			rowNum, ok := data[viewRowNum].(int64)
+			if !ok {
+				return errors.Errorf("cast '%s' field to int", viewRowNum)
+			}
+			delete(data, viewRowNum)
+
+			maxRowNum = max(maxRowNum, rowNum)
+			select {
+			case dataChan <- &domain.Payload{
+				Data:     data,
+				Position: domain.RowNumPosition{WorkerIdx: workerIdx, RowNum: rowNum},
+			}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (s materializedViewStrategy) createMaterializedView(ctx context.Context, viewName string, query string) error {
+	query = fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s", viewName, query)
+	s.logger.Info(ctx, "creating materialized view", log.String("query", query))
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.WithMessagef(err, "exec create materialized view '%s' query", viewName)
+	}
+	return nil
+}
+
+func (s materializedViewStrategy) createViewIndex(ctx context.Context) error {
+	query := fmt.Sprintf("CREATE INDEX %s ON %s (%s, %s)", viewIndexName, viewName, viewRowNum, viewModRowNum)
+	s.logger.Info(ctx, "creating index", log.String("query", query))
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.WithMessagef(err, "exec create index '%s' query", viewIndexName)
+	}
+	return nil
+}
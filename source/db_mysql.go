@@ -0,0 +1,105 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/validator"
+	"github.com/txix-open/mqpusher/conf"
+)
+
+const (
+	mysqlUnsignedPrefix = "UNSIGNED "
+	mysqlMaxLimit       = "18446744073709551615"
+)
+
+// mysqlDialect reads MySQL and MariaDB databases.
+// The text protocol returns values as bytes, so they are parsed according to column types.
+type mysqlDialect struct {
+	cfg conf.DbDataSource
+}
+
+func (d mysqlDialect) name() string {
+	return mysqlDialectName
+}
+
+func (d mysqlDialect) open(ctx context.Context) (*sql.DB, io.Closer, error) {
+	dsn := d.cfg.Dsn
+	if dsn == "" {
+		err := validator.Default.ValidateToError(d.cfg.Client)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "validate db client config")
+		}
+		mysqlCfg := mysql.NewConfig()
+		mysqlCfg.User = d.cfg.Client.Username
+		mysqlCfg.Passwd = d.cfg.Client.Password
+		mysqlCfg.Net = "tcp"
+		mysqlCfg.Addr = fmt.Sprintf("%s:%d", d.cfg.Client.Host, d.cfg.Client.Port)
+		mysqlCfg.DBName = d.cfg.Client.Database
+		mysqlCfg.Params = d.cfg.Client.Params
+		mysqlCfg.ParseTime = true
+		dsn = mysqlCfg.FormatDSN()
+	}
+
+	db, err := sql.Open(mysqlDialectName, dsn)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "open mysql db")
+	}
+	db.SetMaxOpenConns(maxOpenConnections)
+	err = db.PingContext(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, errors.WithMessage(err, "ping mysql db")
+	}
+	return db, db, nil
+}
+
+func (d mysqlDialect) statementBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question)
+}
+
+func (d mysqlDialect) bindVar(_ int) string {
+	return "?"
+}
+
+func (d mysqlDialect) isPositionalBindVar() bool {
+	return true
+}
+
+func (d mysqlDialect) offsetClause(offset int64) string {
+	return fmt.Sprintf("LIMIT %s OFFSET %d", mysqlMaxLimit, offset)
+}
+
+func (d mysqlDialect) partitionCondition(column string, parallel int, workerIdx int) (string, error) {
+	return fmt.Sprintf("MOD(CRC32(%s), %d) = %d", column, parallel, workerIdx), nil
+}
+
+func (d mysqlDialect) columnValue(column *sql.ColumnType, value any) (any, error) {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return value, nil
+	}
+
+	typeName := column.DatabaseTypeName()
+	switch typeName {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		return strconv.ParseInt(string(bytes), 10, 64) // nolint:wrapcheck
+	case "FLOAT", "DOUBLE":
+		return strconv.ParseFloat(string(bytes), 64) // nolint:wrapcheck
+	case "JSON":
+		return decodeJsonColumn(bytes)
+	case "BIT", "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "GEOMETRY":
+		return bytes, nil
+	}
+	if strings.HasPrefix(typeName, mysqlUnsignedPrefix) {
+		return strconv.ParseUint(string(bytes), 10, 64) // nolint:wrapcheck
+	}
+	return string(bytes), nil
+}
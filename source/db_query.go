@@ -0,0 +1,181 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+const (
+	queryAlias = "__mqpusher_query"
+)
+
+type queryStrategy struct {
+	db         *sql.DB
+	dialect    dbDialect
+	logger     log.Logger
+	cfg        conf.DbDataSource
+	checkpoint domain.Checkpoint
+}
+
+func newQueryStrategy(
+	db *sql.DB,
+	dialect dbDialect,
+	logger log.Logger,
+	cfg conf.DbDataSource,
+	checkpoint domain.Checkpoint,
+) queryStrategy {
+	if cfg.PartitionKey == "" {
+		cfg.Parallel = 1
+	}
+	return queryStrategy{
+		db:         db,
+		dialect:    dialect,
+		logger:     logger,
+		cfg:        cfg,
+		checkpoint: checkpoint,
+	}
+}
+
+func (s queryStrategy) prepare(ctx context.Context) (float64, error) {
+	if s.cfg.PartitionKey != "" {
+		_, err := s.dialect.partitionCondition(s.cfg.PartitionKey, s.cfg.Parallel, 0)
+		if err != nil {
+			return 0, errors.WithMessage(err, "build partition condition")
+		}
+	}
+
+	q, args, err := s.bindQuery(fmt.Sprintf("SELECT COUNT(1) FROM (%s) AS %s", s.cfg.Query, queryAlias))
+	if err != nil {
+		return 0, errors.WithMessage(err, "bind count query")
+	}
+
+	var rowsCount float64
+	s.logger.Info(ctx, "selecting rows count", log.String("query", q))
+	err = s.db.QueryRowContext(ctx, q, args...).Scan(&rowsCount)
+	if err != nil {
+		return 0, errors.WithMessage(err, "select query rows count")
+	}
+	s.logger.Info(ctx, fmt.Sprintf("rows count of query: %d", int(rowsCount)))
+
+	return rowsCount, nil
+}
+
+func (s queryStrategy) cleanup(_ context.Context) error { return nil }
+
+func (s queryStrategy) fetch(ctx context.Context, workerIdx int, dataChan chan<- *domain.Payload) error {
+	if workerIdx >= s.cfg.Parallel {
+		return nil
+	}
+
+	skipped := s.checkpoint.RowNums[workerIdx]
+	q := fmt.Sprintf("SELECT * FROM (%s) AS %s", s.cfg.Query, queryAlias)
+	if s.cfg.PartitionKey != "" {
+		column := fmt.Sprintf("%s.%s", queryAlias, s.cfg.PartitionKey)
+		condition, err := s.dialect.partitionCondition(column, s.cfg.Parallel, workerIdx)
+		if err != nil {
+			return errors.WithMessage(err, "build partition condition")
+		}
+		q = fmt.Sprintf("%s WHERE %s", q, condition)
+	}
+	if len(s.cfg.PrimaryKey) > 0 {
+		orderBy := make([]string, len(s.cfg.PrimaryKey))
+		for i, column := range s.cfg.PrimaryKey {
+			orderBy[i] = fmt.Sprintf("%s.%s", queryAlias, column)
+		}
+		q = fmt.Sprintf("%s ORDER BY %s", q, strings.Join(orderBy, ", "))
+	}
+	if skipped > 0 {
+		q = fmt.Sprintf("%s %s", q, s.dialect.offsetClause(skipped))
+	}
+	q, args, err := s.bindQuery(q)
+	if err != nil {
+		return errors.WithMessage(err, "bind query")
+	}
+
+	s.logger.Info(ctx, "executing query", log.String("query", q), log.Int("worker", workerIdx))
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return errors.WithMessage(err, "query context")
+	}
+
+	rowNum := skipped
+	err = eachRow(ctx, s.logger, s.dialect, rows, func(data map[string]any) error {
+		rowNum++
+		select {
+		case dataChan <- &domain.Payload{
+			Data:     data,
+			Position: domain.RowNumPosition{WorkerIdx: workerIdx, RowNum: rowNum},
+		}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		return errors.WithMessage(err, "handle rows")
+	}
+
+	return nil
+}
+
+func (s queryStrategy) bindQuery(query string) (string, []any, error) {
+	var (
+		result     = strings.Builder{}
+		args       = make([]any, 0)
+		argIndexes = make(map[string]int)
+		quote      = rune(0)
+		runes      = []rune(query)
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			result.WriteString("::")
+			i++
+			continue
+		case r == ':' && i+1 < len(runes) && isParamNameStart(runes[i+1]):
+			end := i + 1
+			for end < len(runes) && isParamNameRune(runes[end]) {
+				end++
+			}
+			name := string(runes[i+1 : end])
+			idx, ok := argIndexes[name]
+			if !ok || s.dialect.isPositionalBindVar() {
+				value, ok := s.cfg.QueryParams[name]
+				if !ok {
+					return "", nil, errors.Errorf("query param '%s' is not set", name)
+				}
+				args = append(args, value)
+				idx = len(args)
+				argIndexes[name] = idx
+			}
+			result.WriteString(s.dialect.bindVar(idx))
+			i = end - 1
+			continue
+		}
+		result.WriteRune(r)
+	}
+	return result.String(), args, nil
+}
+
+func isParamNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isParamNameRune(r rune) bool {
+	return isParamNameStart(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/txix-open/mqpusher/conf"
+)
+
+func TestQueryStrategyBindQuery(t *testing.T) {
+	t.Parallel()
+
+	params := map[string]any{"id": 1, "name": "a", "from_date": "2024-01-01"}
+	tests := []struct {
+		name          string
+		dialect       dbDialect
+		query         string
+		expectedQuery string
+		expectedArgs  []any
+		isErr         bool
+	}{
+		{
+			name:          "named params",
+			dialect:       postgresDialect{},
+			query:         "select * from t where id = :id and name = :name",
+			expectedQuery: "select * from t where id = $1 and name = $2",
+			expectedArgs:  []any{1, "a"},
+		},
+		{
+			name:          "repeated param is bound once",
+			dialect:       postgresDialect{},
+			query:         "select * from t where id = :id or parent_id = :id",
+			expectedQuery: "select * from t where id = $1 or parent_id = $1",
+			expectedArgs:  []any{1},
+		},
+		{
+			name:          "repeated param is bound for every placeholder of positional dialect",
+			dialect:       mysqlDialect{},
+			query:         "select * from t where id = :id or parent_id = :id",
+			expectedQuery: "select * from t where id = ? or parent_id = ?",
+			expectedArgs:  []any{1, 1},
+		},
+		{
+			name:          "type cast is kept",
+			dialect:       postgresDialect{},
+			query:         "select created::date from t where created >= :from_date::date",
+			expectedQuery: "select created::date from t where created >= $1::date",
+			expectedArgs:  []any{"2024-01-01"},
+		},
+		{
+			name:          "params in quotes are not bound",
+			dialect:       postgresDialect{},
+			query:         `select ':id', ":name", ` + "`:id`" + ` from t where id = :id`,
+			expectedQuery: `select ':id', ":name", ` + "`:id`" + ` from t where id = $1`,
+			expectedArgs:  []any{1},
+		},
+		{
+			name:          "colon without name is kept",
+			dialect:       sqliteDialect{},
+			query:         "select '12:30', : from t where name = :name",
+			expectedQuery: "select '12:30', : from t where name = ?",
+			expectedArgs:  []any{"a"},
+		},
+		{
+			name:          "query without params",
+			dialect:       postgresDialect{},
+			query:         "select * from t",
+			expectedQuery: "select * from t",
+			expectedArgs:  []any{},
+		},
+		{
+			name:    "unknown param",
+			dialect: postgresDialect{},
+			query:   "select * from t where id = :unknown",
+			isErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			strategy := queryStrategy{
+				dialect: test.dialect,
+				cfg:     conf.DbDataSource{QueryParams: params},
+			}
+			query, args, err := strategy.bindQuery(test.query)
+			if test.isErr {
+				if err == nil {
+					t.Fatalf("expected error, got query '%s'", query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query != test.expectedQuery {
+				t.Fatalf("expected query '%s', got '%s'", test.expectedQuery, query)
+			}
+			if !reflect.DeepEqual(args, test.expectedArgs) {
+				t.Fatalf("expected args %v, got %v", test.expectedArgs, args)
+			}
+		})
+	}
+}
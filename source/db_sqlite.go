@@ -0,0 +1,83 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/txix-open/mqpusher/conf"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect reads SQLite database file, dsn or client database is the path to the file.
+// Values are mapped by declared column types, so JSON and BOOLEAN columns should be declared explicitly.
+type sqliteDialect struct {
+	cfg conf.DbDataSource
+}
+
+func (d sqliteDialect) name() string {
+	return sqliteDialectName
+}
+
+func (d sqliteDialect) open(ctx context.Context) (*sql.DB, io.Closer, error) {
+	dsn := d.cfg.Dsn
+	if dsn == "" {
+		dsn = d.cfg.Client.Database
+	}
+	if dsn == "" {
+		return nil, nil, errors.New("path to sqlite database is not set")
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "open sqlite db")
+	}
+	err = db.PingContext(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, errors.WithMessage(err, "ping sqlite db")
+	}
+	return db, db, nil
+}
+
+func (d sqliteDialect) statementBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question)
+}
+
+func (d sqliteDialect) bindVar(_ int) string {
+	return "?"
+}
+
+func (d sqliteDialect) isPositionalBindVar() bool {
+	return true
+}
+
+func (d sqliteDialect) offsetClause(offset int64) string {
+	return fmt.Sprintf("LIMIT -1 OFFSET %d", offset)
+}
+
+func (d sqliteDialect) partitionCondition(_ string, _ int, _ int) (string, error) {
+	return "", errors.New("partition key is not supported by sqlite dialect")
+}
+
+func (d sqliteDialect) columnValue(column *sql.ColumnType, value any) (any, error) {
+	typeName := column.DatabaseTypeName()
+	switch {
+	case typeName == "JSON" || typeName == "JSONB":
+		switch v := value.(type) {
+		case string:
+			return decodeJsonColumn([]byte(v))
+		case []byte:
+			return decodeJsonColumn(v)
+		}
+	case typeName == "BOOLEAN" || typeName == "BOOL":
+		v, ok := value.(int64)
+		if ok {
+			return v != 0, nil
+		}
+	}
+	return value, nil
+}
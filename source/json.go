@@ -3,48 +3,66 @@ package source
 import (
 	"bufio"
 	"context"
-	"os"
+	stdjson "encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/conf"
 	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
 )
 
 const (
-	maxScannerBuf = 1 << 20 // 1 MB
+	initScannerBuf       = 64 << 10 // 64 KB
+	defaultMaxRecordSize = 1 << 20  // 1 MB
 )
 
 type jsonDataSource struct {
 	scanner   *bufio.Scanner
-	closeFile func() error
+	inputFile utils.InputFile
 
 	readCounter      *atomic.Uint64
 	readBytesCounter *atomic.Uint64
-	fileSize         float64
 	isPlainTextMode  bool
+	maxRecordSize    int
+	startOffset      int64
+	recordPath       string
 }
 
-func NewJson(filePath string, isPlainTextMode bool) (jsonDataSource, error) {
-	file, err := os.Open(filePath)
+func NewJson(cfg conf.JsonDataSource, isPlainTextMode bool, checkpoint domain.Checkpoint) (jsonDataSource, error) {
+	inputFile, err := utils.OpenInputFile(cfg.FilePath, checkpoint.Offset)
 	if err != nil {
-		return jsonDataSource{}, errors.WithMessagef(err, "open file '%s'", filePath)
-	}
-	info, err := file.Stat()
-	if err != nil {
-		return jsonDataSource{}, errors.WithMessage(err, "file stat")
+		return jsonDataSource{}, errors.WithMessage(err, "open input file")
 	}
 
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, maxScannerBuf), maxScannerBuf)
+	readBytesCounter := new(atomic.Uint64)
+	readBytesCounter.Store(uint64(checkpoint.Offset)) // nolint:gosec
+
+	maxRecordSize := cfg.MaxRecordSize
+	if maxRecordSize <= 0 {
+		maxRecordSize = defaultMaxRecordSize
+	}
+	scanner := bufio.NewScanner(inputFile)
+	scanner.Buffer(make([]byte, min(initScannerBuf, maxRecordSize)), maxRecordSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		readBytesCounter.Add(uint64(advance)) // nolint:gosec
+		return advance, token, err
+	})
 
 	return jsonDataSource{
 		scanner:          scanner,
-		closeFile:        file.Close,
+		inputFile:        inputFile,
 		readCounter:      new(atomic.Uint64),
-		readBytesCounter: new(atomic.Uint64),
-		fileSize:         float64(info.Size()),
+		readBytesCounter: readBytesCounter,
 		isPlainTextMode:  isPlainTextMode,
+		maxRecordSize:    maxRecordSize,
+		startOffset:      checkpoint.Offset,
+		recordPath:       cfg.RecordPath,
 	}, nil
 }
 
@@ -52,44 +70,97 @@ func (j jsonDataSource) GetData(_ context.Context) (*domain.Payload, error) {
 	ok := j.scanner.Scan()
 	if !ok {
 		err := j.scanner.Err()
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, errors.Errorf("record on %s exceeds max record size of %d bytes",
+				j.lineDescription(j.readCounter.Load()+1), j.maxRecordSize)
+		}
 		if err != nil {
-			return nil, errors.WithMessage(err, "scan")
+			return nil, errors.WithMessagef(err, "scan %s", j.lineDescription(j.readCounter.Load()+1))
 		}
 		return nil, domain.ErrNoData
 	}
-	bytes := j.scanner.Bytes()
+	bytes := slices.Clone(j.scanner.Bytes())
+	position := domain.OffsetPosition(j.readBytesCounter.Load()) // nolint:gosec
+	lineNum := j.readCounter.Add(1)
+	if j.recordPath != "" {
+		record, err := recordField(bytes, j.recordPath, j.isPlainTextMode)
+		if err != nil {
+			return nil, domain.NewRecordError(
+				errors.WithMessagef(err, "extract record on %s", j.lineDescription(lineNum)),
+				bytes, position,
+			)
+		}
+		bytes = record
+	}
 	payload := &domain.Payload{
-		Data: bytes,
+		Data:     bytes,
+		Raw:      bytes,
+		Position: position,
 	}
 
 	if !j.isPlainTextMode {
 		var data any
 		err := json.Unmarshal(bytes, &data)
 		if err != nil {
-			return nil, errors.WithMessage(err, "unmarshal row")
+			return nil, domain.NewRecordError(
+				errors.WithMessagef(err, "unmarshal row on %s", j.lineDescription(lineNum)),
+				bytes, payload.Position,
+			)
 		}
 		payload.Data = data
 	}
 
-	j.readBytesCounter.Add(uint64(len(bytes)))
-	j.readCounter.Add(1)
-
 	return payload, nil
 }
 
-//nolint:mnd
+// lineDescription describes line by its number counted from start offset.
+func (j jsonDataSource) lineDescription(lineNum uint64) string {
+	line := fmt.Sprintf("line %d", lineNum)
+	if j.startOffset > 0 {
+		line += fmt.Sprintf(" after offset %d", j.startOffset)
+	}
+	return line
+}
+
 func (j jsonDataSource) Progress() domain.Progress {
-	readDataPercent := float64(j.readBytesCounter.Load()) / j.fileSize * 100
 	return domain.Progress{
 		ReadDataCount:   j.readCounter.Load(),
-		ReadDataPercent: &readDataPercent,
+		ReadDataPercent: j.inputFile.ReadPercent(),
 	}
 }
 
 func (j jsonDataSource) Close(_ context.Context) error {
-	err := j.closeFile()
+	err := j.inputFile.Close()
 	if err != nil {
-		return errors.WithMessage(err, "close file")
+		return errors.WithMessage(err, "close input file")
 	}
 	return nil
 }
+
+// recordField returns JSON value of field by dot-separated path of record, e.g. 'data' of reject file record.
+// In plain text mode string value is returned without quotes.
+func recordField(record []byte, recordPath string, isPlainTextMode bool) ([]byte, error) {
+	value := stdjson.RawMessage(record)
+	for _, field := range strings.Split(recordPath, ".") {
+		var obj map[string]stdjson.RawMessage
+		err := stdjson.Unmarshal(value, &obj)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unmarshal object containing field '%s'", field)
+		}
+		var ok bool
+		value, ok = obj[field]
+		if !ok {
+			return nil, errors.Errorf("field '%s' is not found", field)
+		}
+	}
+
+	if isPlainTextMode && len(value) > 0 && value[0] == '"' {
+		var s string
+		err := stdjson.Unmarshal(value, &s)
+		if err != nil {
+			return nil, errors.WithMessage(err, "unmarshal string value")
+		}
+		return []byte(s), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,169 @@
+package source
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
+)
+
+// jsonArrayDataSource streams elements of JSON array located by dot-separated path
+// (empty path means root array) without reading the whole document into memory.
+type jsonArrayDataSource struct {
+	decoder   *stdjson.Decoder
+	inputFile utils.InputFile
+
+	readCounter     *atomic.Uint64
+	elementCounter  *atomic.Int64
+	isPlainTextMode bool
+	recordPath      string
+}
+
+func NewJsonArray(
+	filePath string,
+	arrayPath string,
+	recordPath string,
+	isPlainTextMode bool,
+	checkpoint domain.Checkpoint,
+) (jsonArrayDataSource, error) {
+	inputFile, err := utils.OpenInputFile(filePath, 0)
+	if err != nil {
+		return jsonArrayDataSource{}, errors.WithMessage(err, "open input file")
+	}
+
+	decoder := stdjson.NewDecoder(inputFile)
+	err = seekArray(decoder, arrayPath)
+	if err != nil {
+		_ = inputFile.Close()
+		return jsonArrayDataSource{}, errors.WithMessagef(err, "seek array by path '%s'", arrayPath)
+	}
+
+	skipCount := checkpoint.RowNums[0]
+	for i := int64(0); i < skipCount && decoder.More(); i++ {
+		err = decoder.Decode(new(stdjson.RawMessage))
+		if err != nil {
+			_ = inputFile.Close()
+			return jsonArrayDataSource{}, errors.WithMessagef(err, "skip array element %d", i)
+		}
+	}
+
+	elementCounter := new(atomic.Int64)
+	elementCounter.Store(skipCount)
+	return jsonArrayDataSource{
+		decoder:         decoder,
+		inputFile:       inputFile,
+		readCounter:     new(atomic.Uint64),
+		elementCounter:  elementCounter,
+		isPlainTextMode: isPlainTextMode,
+		recordPath:      recordPath,
+	}, nil
+}
+
+func seekArray(decoder *stdjson.Decoder, arrayPath string) error {
+	var path []string
+	if arrayPath != "" {
+		path = strings.Split(arrayPath, ".")
+	}
+
+	for _, key := range path {
+		err := expectDelim(decoder, '{')
+		if err != nil {
+			return errors.WithMessagef(err, "read object containing '%s'", key)
+		}
+		err = seekKey(decoder, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := expectDelim(decoder, '[')
+	if err != nil {
+		return errors.WithMessage(err, "read array")
+	}
+	return nil
+}
+
+func seekKey(decoder *stdjson.Decoder, key string) error {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return errors.WithMessage(err, "read object key")
+		}
+		if token == key {
+			return nil
+		}
+		err = decoder.Decode(new(stdjson.RawMessage))
+		if err != nil {
+			return errors.WithMessagef(err, "skip value of key '%v'", token)
+		}
+	}
+	return errors.Errorf("key '%s' not found", key)
+}
+
+func expectDelim(decoder *stdjson.Decoder, delim stdjson.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.WithMessage(err, "read token")
+	}
+	if token != delim {
+		return errors.Errorf("expected '%s', got '%v'", delim, token)
+	}
+	return nil
+}
+
+func (j jsonArrayDataSource) GetData(_ context.Context) (*domain.Payload, error) {
+	if !j.decoder.More() {
+		return nil, domain.ErrNoData
+	}
+
+	var raw stdjson.RawMessage
+	err := j.decoder.Decode(&raw)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decode array element")
+	}
+	position := domain.RowNumPosition{WorkerIdx: 0, RowNum: j.elementCounter.Add(1)}
+	j.readCounter.Add(1)
+	if j.recordPath != "" {
+		record, err := recordField(raw, j.recordPath, j.isPlainTextMode)
+		if err != nil {
+			return nil, domain.NewRecordError(errors.WithMessage(err, "extract record of array element"), raw, position)
+		}
+		raw = record
+	}
+	payload := &domain.Payload{
+		Data:     []byte(raw),
+		Raw:      raw,
+		Position: position,
+	}
+
+	if !j.isPlainTextMode {
+		var data any
+		err := json.Unmarshal(raw, &data)
+		if err != nil {
+			return nil, domain.NewRecordError(errors.WithMessage(err, "unmarshal array element"), raw, payload.Position)
+		}
+		payload.Data = data
+	}
+
+	return payload, nil
+}
+
+func (j jsonArrayDataSource) Progress() domain.Progress {
+	return domain.Progress{
+		ReadDataCount:   j.readCounter.Load(),
+		ReadDataPercent: j.inputFile.ReadPercent(),
+	}
+}
+
+func (j jsonArrayDataSource) Close(_ context.Context) error {
+	err := j.inputFile.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close input file")
+	}
+	return nil
+}
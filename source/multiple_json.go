@@ -5,89 +5,302 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/conf"
 	"github.com/txix-open/mqpusher/domain"
 	"github.com/txix-open/mqpusher/utils"
 )
 
+const (
+	ndjsonFileMode = "ndjson"
+
+	ndjsonFileResultBuf = 100
+)
+
+type readFileJob struct {
+	fileIdx int
+	file    utils.DirFile
+	offset  int64
+	result  chan<- fileData
+}
+
+type fileData struct {
+	payload *domain.Payload
+	err     error
+}
+
+// multipleJsonDataSources reads json files of directory by parallel workers.
+// Each file is read as a whole document or as NDJSON depending on file mode.
+// In ordered mode data is returned in the order of files,
+// otherwise in the order it is read.
 type multipleJsonDataSources struct {
-	fileManager      *utils.FileManager
+	orderedResults chan chan fileData
+	currentResult  *chan fileData
+	results        chan fileData
+	cancel         context.CancelFunc
+
 	readCounter      *atomic.Uint64
 	readBytesCounter *atomic.Uint64
 	filesSize        float64
 	isPlainTextMode  bool
+	isUnordered      bool
+	isNdjsonFileMode bool
+	maxRecordSize    int
+	recordPath       string
 }
 
-func NewMultipleJson(dirPath string, isPlainTextMode bool) (multipleJsonDataSources, error) {
-	dir, err := os.Open(dirPath)
+func NewMultipleJson(
+	ctx context.Context,
+	cfg conf.JsonDataSource,
+	isPlainTextMode bool,
+	checkpoint domain.Checkpoint,
+) (multipleJsonDataSources, error) {
+	files, err := utils.ListDirFiles(cfg.FilePath, utils.ListOptions{
+		IsRecursive: cfg.Directory.IsRecursive,
+		Include:     cfg.Directory.Include,
+		Exclude:     cfg.Directory.Exclude,
+		SortOrder:   cfg.Directory.SortOrder,
+	})
 	if err != nil {
-		return multipleJsonDataSources{}, errors.WithMessagef(err, "open dir '%s'", dirPath)
-	}
-	defer func() {
-		_ = dir.Close()
-	}()
-
-	files, err := dir.ReadDir(0)
-	if err != nil {
-		return multipleJsonDataSources{}, errors.WithMessagef(err, "read dir '%s'", dirPath)
+		return multipleJsonDataSources{}, errors.WithMessage(err, "list dir files")
 	}
 
+	// progress is counted in file sizes, offset of resumed file is not added
+	// as it is counted in decompressed bytes for compressed file
 	var (
-		fileNames = make([]string, 0)
-		filesSize float64
+		filesSize   float64
+		skippedSize float64
+		startIdx    = min(checkpoint.FileIdx, len(files))
 	)
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	for i, file := range files {
+		if i < startIdx {
+			skippedSize += float64(file.Size)
 		}
-		info, err := file.Info()
-		if err != nil {
-			return multipleJsonDataSources{}, errors.WithMessagef(err, "file '%s' info", file.Name())
-		}
-		fileNames = append(fileNames, filepath.Join(dirPath, file.Name()))
-		filesSize += float64(info.Size())
+		filesSize += float64(file.Size)
 	}
 
-	return multipleJsonDataSources{
-		fileManager:      utils.NewFileManager(fileNames),
+	parallel := max(cfg.Parallel, 1)
+	readBytesCounter := new(atomic.Uint64)
+	readBytesCounter.Store(uint64(skippedSize))
+
+	ctx, cancel := context.WithCancel(ctx)
+	dataSource := multipleJsonDataSources{
+		orderedResults:   make(chan chan fileData, parallel),
+		currentResult:    new(chan fileData),
+		results:          make(chan fileData, parallel),
+		cancel:           cancel,
 		readCounter:      new(atomic.Uint64),
-		readBytesCounter: new(atomic.Uint64),
+		readBytesCounter: readBytesCounter,
 		filesSize:        filesSize,
 		isPlainTextMode:  isPlainTextMode,
-	}, nil
+		isUnordered:      cfg.IsUnordered,
+		isNdjsonFileMode: cfg.FileMode == ndjsonFileMode,
+		maxRecordSize:    cfg.MaxRecordSize,
+		recordPath:       cfg.RecordPath,
+	}
+	go dataSource.startReadingFiles(ctx, files, startIdx, checkpoint.Offset, parallel)
+
+	return dataSource, nil
 }
 
-func (m multipleJsonDataSources) GetData(_ context.Context) (*domain.Payload, error) {
-	filePath, ok := m.fileManager.Next()
-	if !ok {
-		return nil, domain.ErrNoData
+func (m multipleJsonDataSources) startReadingFiles(
+	ctx context.Context,
+	files []utils.DirFile,
+	startIdx int,
+	startOffset int64,
+	parallel int,
+) {
+	jobs := make(chan readFileJob)
+	wg := new(sync.WaitGroup)
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				m.readFile(ctx, job)
+			}
+		}()
 	}
 
+	defer func() {
+		close(jobs)
+		wg.Wait()
+		close(m.results)
+	}()
+	defer close(m.orderedResults)
+
+	resultBuf := 1
+	if m.isNdjsonFileMode {
+		resultBuf = ndjsonFileResultBuf
+	}
+	for idx := startIdx; idx < len(files); idx++ {
+		job := readFileJob{fileIdx: idx, file: files[idx], result: m.results}
+		if idx == startIdx {
+			job.offset = startOffset
+		}
+		if !m.isUnordered {
+			orderedResult := make(chan fileData, resultBuf)
+			select {
+			case m.orderedResults <- orderedResult:
+			case <-ctx.Done():
+				return
+			}
+			job.result = orderedResult
+		}
+
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m multipleJsonDataSources) readFile(ctx context.Context, job readFileJob) {
+	if !m.isUnordered {
+		defer close(job.result)
+	}
+
+	if m.isNdjsonFileMode {
+		m.readNdjsonFile(ctx, job)
+		return
+	}
+
+	select {
+	case job.result <- m.readWholeFile(job.fileIdx, job.file.Path):
+	case <-ctx.Done():
+	}
+}
+
+func (m multipleJsonDataSources) readWholeFile(fileIdx int, filePath string) fileData {
 	bytes, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, errors.WithMessagef(err, "read file '%s'", filePath)
+		return fileData{err: errors.WithMessagef(err, "read file '%s'", filePath)}
+	}
+	position := domain.FileIdxPosition(fileIdx + 1)
+	m.readBytesCounter.Add(uint64(len(bytes)))
+	if m.recordPath != "" {
+		record, err := recordField(bytes, m.recordPath, m.isPlainTextMode)
+		if err != nil {
+			err = errors.WithMessagef(err, "extract record of file '%s'", filePath)
+			return fileData{err: domain.NewRecordError(err, bytes, position)}
+		}
+		bytes = record
 	}
 	payload := &domain.Payload{
 		RequestId: m.requestIdFromPath(filePath),
 		Data:      bytes,
+		Raw:       bytes,
+		Position:  position,
 	}
 
 	if !m.isPlainTextMode {
 		var data any
 		err = json.Unmarshal(bytes, &data)
 		if err != nil {
-			return nil, errors.WithMessagef(err, "unmarshal data from file '%s'", filePath)
+			err = errors.WithMessagef(err, "unmarshal data from file '%s'", filePath)
+			return fileData{err: domain.NewRecordError(err, bytes, payload.Position)}
 		}
 		payload.Data = data
 	}
 
-	m.readBytesCounter.Add(uint64(len(bytes)))
-	m.readCounter.Add(1)
+	return fileData{payload: payload}
+}
+
+func (m multipleJsonDataSources) readNdjsonFile(ctx context.Context, job readFileJob) {
+	send := func(data fileData) bool {
+		select {
+		case job.result <- data:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	src, err := NewJson(
+		conf.JsonDataSource{FilePath: job.file.Path, MaxRecordSize: m.maxRecordSize, RecordPath: m.recordPath},
+		m.isPlainTextMode,
+		domain.Checkpoint{Offset: job.offset},
+	)
+	if err != nil {
+		send(fileData{err: errors.WithMessagef(err, "new json data source of file '%s'", job.file.Path)})
+		return
+	}
+	defer func() {
+		_ = src.Close(ctx)
+	}()
 
-	return payload, nil
+	for {
+		payload, err := src.GetData(ctx)
+		recordErr := new(domain.RecordError)
+		switch {
+		case errors.Is(err, domain.ErrNoData):
+			m.readBytesCounter.Add(uint64(job.file.Size)) // nolint:gosec
+			return
+		case errors.As(err, &recordErr):
+			recordErr.Position = m.filePosition(job.fileIdx, recordErr.Position)
+			err = errors.WithMessagef(err, "file '%s'", job.file.Path)
+		case err != nil:
+			send(fileData{err: errors.WithMessagef(err, "read file '%s'", job.file.Path)})
+			return
+		default:
+			payload.Position = m.filePosition(job.fileIdx, payload.Position)
+		}
+
+		if !send(fileData{payload: payload, err: err}) {
+			return
+		}
+	}
+}
+
+func (m multipleJsonDataSources) filePosition(fileIdx int, position domain.Position) domain.Position {
+	offset, _ := position.(domain.OffsetPosition)
+	return domain.FilePosition{FileIdx: fileIdx, Offset: int64(offset)}
+}
+
+func (m multipleJsonDataSources) GetData(ctx context.Context) (*domain.Payload, error) {
+	if m.isUnordered {
+		select {
+		case data, ok := <-m.results:
+			if !ok {
+				return nil, domain.ErrNoData
+			}
+			m.readCounter.Add(1)
+			return data.payload, data.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	for {
+		if *m.currentResult == nil {
+			select {
+			case result, ok := <-m.orderedResults:
+				if !ok {
+					return nil, domain.ErrNoData
+				}
+				*m.currentResult = result
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		select {
+		case data, ok := <-*m.currentResult:
+			if !ok {
+				*m.currentResult = nil
+				continue
+			}
+			m.readCounter.Add(1)
+			return data.payload, data.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 }
 
 //nolint:mnd
@@ -99,7 +312,10 @@ func (m multipleJsonDataSources) Progress() domain.Progress {
 	}
 }
 
-func (m multipleJsonDataSources) Close(_ context.Context) error { return nil }
+func (m multipleJsonDataSources) Close(_ context.Context) error {
+	m.cancel()
+	return nil
+}
 
 func (m multipleJsonDataSources) requestIdFromPath(filePath string) string {
 	_, fileName := filepath.Split(filePath)
@@ -0,0 +1,185 @@
+package source
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+	parquetsource "github.com/xitongsys/parquet-go/source"
+)
+
+const (
+	parquetReadBatchSize = 1000
+)
+
+// parquetDataSource streams rows of parquet file by batches, row groups are read one by one.
+// Only selected top-level columns are read if they are set.
+type parquetDataSource struct {
+	file     *parquetFile
+	reader   *reader.ParquetReader
+	schema   parquetSchema
+	filePath string
+	batch    *[]any
+
+	readCounter *atomic.Uint64
+	rowNum      *atomic.Int64
+	rowsCount   int64
+}
+
+func NewParquet(cfg conf.ParquetDataSource, checkpoint domain.Checkpoint) (parquetDataSource, error) {
+	if cfg.FilePath == utils.StdinPath {
+		return parquetDataSource{}, errors.New("reading parquet file from stdin is not supported")
+	}
+
+	file, err := openParquetFile(cfg.FilePath)
+	if err != nil {
+		return parquetDataSource{}, errors.WithMessage(err, "open parquet file")
+	}
+	parquetReader, err := reader.NewParquetReader(file, nil, int64(runtime.GOMAXPROCS(0)))
+	if err != nil {
+		_ = file.Close()
+		return parquetDataSource{}, errors.WithMessagef(err, "read metadata of parquet file '%s'", cfg.FilePath)
+	}
+
+	dataSource := parquetDataSource{
+		file:        file,
+		reader:      parquetReader,
+		filePath:    cfg.FilePath,
+		batch:       new([]any),
+		readCounter: new(atomic.Uint64),
+		rowNum:      new(atomic.Int64),
+		rowsCount:   parquetReader.GetNumRows(),
+	}
+
+	skipCount := min(checkpoint.RowNums[0], dataSource.rowsCount)
+	err = parquetReader.SkipRows(skipCount)
+	if err != nil {
+		_ = dataSource.Close(context.Background())
+		return parquetDataSource{}, errors.WithMessagef(err, "skip %d rows", skipCount)
+	}
+	dataSource.rowNum.Store(skipCount)
+
+	dataSource.schema, err = newParquetSchema(parquetReader.SchemaHandler, cfg.SelectedColumns)
+	if err != nil {
+		_ = dataSource.Close(context.Background())
+		return parquetDataSource{}, errors.WithMessage(err, "define parquet schema")
+	}
+	dataSource.dropUnselectedColumns()
+
+	return dataSource, nil
+}
+
+// dropUnselectedColumns closes buffers of columns which are not selected, so they are not read.
+func (p parquetDataSource) dropUnselectedColumns() {
+	if len(p.schema.selected) == len(p.schema.children[0]) {
+		return
+	}
+
+	rootName := p.reader.SchemaHandler.GetRootInName()
+	for path, buffer := range p.reader.ColumnBuffers {
+		columnPath := common.StrToPath(path)
+		if len(columnPath) > 1 && columnPath[0] == rootName && slices.Contains(p.schema.selectedInNames, columnPath[1]) {
+			continue
+		}
+		if buffer != nil && buffer.PFile != nil {
+			_ = buffer.PFile.Close()
+		}
+		delete(p.reader.ColumnBuffers, path)
+	}
+}
+
+func (p parquetDataSource) GetData(_ context.Context) (*domain.Payload, error) {
+	if len(*p.batch) == 0 {
+		rowNum := p.rowNum.Load()
+		if rowNum >= p.rowsCount {
+			return nil, domain.ErrNoData
+		}
+		batch, err := p.reader.ReadByNumber(int(min(parquetReadBatchSize, p.rowsCount-rowNum)))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read rows after row %d of parquet file '%s'", rowNum, p.filePath)
+		}
+		if len(batch) == 0 {
+			return nil, domain.ErrNoData
+		}
+		*p.batch = batch
+	}
+
+	row := (*p.batch)[0]
+	*p.batch = (*p.batch)[1:]
+	rowNum := p.rowNum.Add(1)
+	p.readCounter.Add(1)
+
+	metadata := domain.NewMetadata()
+	metadata.SourceFile = p.filePath
+	metadata.SourceRow = rowNum
+	return &domain.Payload{
+		Data:     p.schema.row(row),
+		Metadata: metadata,
+		Position: domain.RowNumPosition{WorkerIdx: 0, RowNum: rowNum},
+	}, nil
+}
+
+//nolint:mnd
+func (p parquetDataSource) Progress() domain.Progress {
+	readDataPercent := float64(100)
+	if p.rowsCount > 0 {
+		readDataPercent = float64(p.rowNum.Load()) / float64(p.rowsCount) * 100
+	}
+	return domain.Progress{
+		ReadDataCount:   p.readCounter.Load(),
+		ReadDataPercent: &readDataPercent,
+	}
+}
+
+func (p parquetDataSource) Close(_ context.Context) error {
+	p.reader.ReadStop()
+	err := p.file.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close parquet file")
+	}
+	return nil
+}
+
+// parquetFile is read-only local file for parquet reader, which opens the file once more for every column.
+type parquetFile struct {
+	*os.File
+	path string
+}
+
+func openParquetFile(path string) (*parquetFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "open file '%s'", path)
+	}
+	return &parquetFile{File: file, path: path}, nil
+}
+
+// nolint:ireturn
+func (f *parquetFile) Open(name string) (parquetsource.ParquetFile, error) {
+	if name == "" {
+		name = f.path
+	}
+	return openParquetFile(name)
+}
+
+// nolint:ireturn
+func (f *parquetFile) Create(_ string) (parquetsource.ParquetFile, error) {
+	return nil, errors.New("parquet file is read-only")
+}
+
+func (f *parquetFile) Read(b []byte) (int, error) {
+	n, err := io.ReadFull(f.File, b)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, io.EOF
+	}
+	return n, err // nolint:wrapcheck
+}
@@ -0,0 +1,278 @@
+package source
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+const (
+	parquetListName     = "List"
+	parquetElementName  = "Element"
+	parquetKeyValueName = "Key_value"
+	parquetKeyName      = "Key"
+	parquetValueName    = "Value"
+)
+
+// parquetSchema converts rows read by parquet reader as structs of generated types
+// to maps with original column names and values of types according to logical types of columns.
+type parquetSchema struct {
+	handler         *schema.SchemaHandler
+	children        [][]int32
+	selected        []int32
+	selectedInNames []string
+}
+
+func newParquetSchema(handler *schema.SchemaHandler, selectedColumns []string) (parquetSchema, error) {
+	children := schemaChildren(handler.SchemaElements)
+	s := parquetSchema{
+		handler:  handler,
+		children: children,
+	}
+
+	if len(selectedColumns) == 0 {
+		s.selected = children[0]
+	}
+	for _, column := range selectedColumns {
+		idx := slices.IndexFunc(children[0], func(idx int32) bool {
+			return handler.GetExName(int(idx)) == column
+		})
+		if idx < 0 {
+			return parquetSchema{}, errors.Errorf("column '%s' not found", column)
+		}
+		s.selected = append(s.selected, children[0][idx])
+	}
+	for _, idx := range s.selected {
+		s.selectedInNames = append(s.selectedInNames, handler.GetInName(int(idx)))
+	}
+	return s, nil
+}
+
+// schemaChildren returns indexes of children of every schema element, elements are stored in depth-first order.
+func schemaChildren(elements []*parquet.SchemaElement) [][]int32 {
+	children := make([][]int32, len(elements))
+	stack := make([]int32, 0)
+	remaining := make([]int32, 0)
+	for i, element := range elements {
+		idx := int32(i) // nolint:gosec
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			children[parent] = append(children[parent], idx)
+			remaining[len(remaining)-1]--
+		}
+		stack = append(stack, idx)
+		remaining = append(remaining, element.GetNumChildren())
+		for len(stack) > 0 && remaining[len(remaining)-1] == 0 {
+			stack = stack[:len(stack)-1]
+			remaining = remaining[:len(remaining)-1]
+		}
+	}
+	return children
+}
+
+func (s parquetSchema) row(row any) map[string]any {
+	v := reflect.ValueOf(row)
+	data := make(map[string]any, len(s.selected))
+	for _, idx := range s.selected {
+		field := v.FieldByName(s.handler.GetInName(int(idx)))
+		data[s.handler.GetExName(int(idx))] = s.value(idx, field)
+	}
+	return data
+}
+
+func (s parquetSchema) value(idx int32, v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	element := s.handler.SchemaElements[idx]
+	isRepeated := element.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED
+
+	if elementIdx, ok := s.listElement(idx); ok {
+		return s.list(v, func(item reflect.Value) any { return s.value(elementIdx, item) })
+	}
+	if keyIdx, valueIdx, ok := s.mapKeyValue(idx); ok {
+		if v.IsNil() {
+			return nil
+		}
+		result := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(s.value(keyIdx, iter.Key()))
+			result[key] = s.value(valueIdx, iter.Value())
+		}
+		return result
+	}
+	if element.GetNumChildren() == 0 {
+		if isRepeated {
+			return s.list(v, func(item reflect.Value) any { return leafValue(element, item.Interface()) })
+		}
+		return leafValue(element, v.Interface())
+	}
+	if isRepeated {
+		return s.list(v, func(item reflect.Value) any { return s.structValue(idx, item) })
+	}
+	return s.structValue(idx, v)
+}
+
+func (s parquetSchema) list(v reflect.Value, itemValue func(item reflect.Value) any) any {
+	if v.IsNil() {
+		return nil
+	}
+	result := make([]any, v.Len())
+	for i := range v.Len() {
+		result[i] = itemValue(v.Index(i))
+	}
+	return result
+}
+
+func (s parquetSchema) structValue(idx int32, v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	result := make(map[string]any, len(s.children[idx]))
+	for _, childIdx := range s.children[idx] {
+		field := v.FieldByName(s.handler.GetInName(int(childIdx)))
+		result[s.handler.GetExName(int(childIdx))] = s.value(childIdx, field)
+	}
+	return result
+}
+
+// listElement detects list of three-level structure, which is read as slice of elements.
+func (s parquetSchema) listElement(idx int32) (int32, bool) {
+	element := s.handler.SchemaElements[idx]
+	if !element.IsSetConvertedType() || element.GetConvertedType() != parquet.ConvertedType_LIST {
+		return 0, false
+	}
+	list, ok := s.singleChild(idx, parquetListName)
+	if !ok {
+		return 0, false
+	}
+	return s.singleChild(list, parquetElementName)
+}
+
+// mapKeyValue detects map of three-level structure, which is read as go map.
+func (s parquetSchema) mapKeyValue(idx int32) (int32, int32, bool) {
+	element := s.handler.SchemaElements[idx]
+	if !element.IsSetConvertedType() || element.GetConvertedType() != parquet.ConvertedType_MAP {
+		return 0, 0, false
+	}
+	keyValue, ok := s.singleChild(idx, parquetKeyValueName)
+	if !ok || len(s.children[keyValue]) != 2 { // nolint:mnd
+		return 0, 0, false
+	}
+	keyIdx, valueIdx := s.children[keyValue][0], s.children[keyValue][1]
+	if s.handler.GetInName(int(keyIdx)) != parquetKeyName || s.handler.GetInName(int(valueIdx)) != parquetValueName {
+		return 0, 0, false
+	}
+	return keyIdx, valueIdx, true
+}
+
+func (s parquetSchema) singleChild(idx int32, inName string) (int32, bool) {
+	if len(s.children[idx]) != 1 {
+		return 0, false
+	}
+	child := s.children[idx][0]
+	return child, s.handler.GetInName(int(child)) == inName
+}
+
+// nolint:cyclop,gocognit
+func leafValue(element *parquet.SchemaElement, value any) any {
+	convertedType := parquet.ConvertedType(-1)
+	if element.IsSetConvertedType() {
+		convertedType = element.GetConvertedType()
+	}
+	logicalType := element.GetLogicalType()
+	isDecimal := convertedType == parquet.ConvertedType_DECIMAL || logicalType != nil && logicalType.IsSetDECIMAL()
+	scale := int64(element.GetScale())
+
+	switch v := value.(type) {
+	case string:
+		switch {
+		case element.GetType() == parquet.Type_INT96:
+			return types.INT96ToTime(v)
+		case isDecimal:
+			return decimalString(bytesToBigInt([]byte(v)), scale)
+		case convertedType == parquet.ConvertedType_JSON:
+			var result any
+			err := json.Unmarshal([]byte(v), &result)
+			if err != nil {
+				return v
+			}
+			return result
+		case convertedType == parquet.ConvertedType_UTF8 || convertedType == parquet.ConvertedType_ENUM || utf8.ValidString(v):
+			return v
+		default:
+			return []byte(v)
+		}
+	case int32:
+		switch {
+		case isDecimal:
+			return decimalString(big.NewInt(int64(v)), scale)
+		case convertedType == parquet.ConvertedType_DATE || logicalType != nil && logicalType.IsSetDATE():
+			return time.Unix(int64(v)*int64(24*time.Hour/time.Second), 0).UTC().Format(time.DateOnly)
+		case convertedType == parquet.ConvertedType_UINT_8 ||
+			convertedType == parquet.ConvertedType_UINT_16 ||
+			convertedType == parquet.ConvertedType_UINT_32:
+			return uint32(v) // nolint:gosec
+		default:
+			return v
+		}
+	case int64:
+		switch {
+		case isDecimal:
+			return decimalString(big.NewInt(v), scale)
+		case convertedType == parquet.ConvertedType_UINT_64:
+			return uint64(v) // nolint:gosec
+		case convertedType == parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return types.TIMESTAMP_MILLISToTime(v, true)
+		case convertedType == parquet.ConvertedType_TIMESTAMP_MICROS:
+			return types.TIMESTAMP_MICROSToTime(v, true)
+		case logicalType != nil && logicalType.IsSetTIMESTAMP():
+			unit := logicalType.GetTIMESTAMP().GetUnit()
+			switch {
+			case unit.IsSetMILLIS():
+				return types.TIMESTAMP_MILLISToTime(v, true)
+			case unit.IsSetMICROS():
+				return types.TIMESTAMP_MICROSToTime(v, true)
+			default:
+				return types.TIMESTAMP_NANOSToTime(v, true)
+			}
+		default:
+			return v
+		}
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+}
+
+// decimalString formats decimal without loss of precision.
+func decimalString(unscaled *big.Int, scale int64) string {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil) // nolint:mnd
+	return new(big.Rat).SetFrac(unscaled, denominator).FloatString(int(scale))
+}
+
+// bytesToBigInt decodes big-endian two's complement integer.
+func bytesToBigInt(b []byte) *big.Int {
+	result := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		result.Sub(result, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8)) // nolint:mnd
+	}
+	return result
+}
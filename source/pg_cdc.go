@@ -0,0 +1,520 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/validator"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+)
+
+const (
+	pgoutputPlugin = "pgoutput"
+	wal2jsonPlugin = "wal2json"
+)
+
+const (
+	pgStatusIntervalInSec = 10
+	pgDuplicateObjectCode = "42710"
+	pgDefaultSchema       = "public"
+)
+
+const (
+	pgXLogDataMessage            = 'w'
+	pgPrimaryKeepaliveMessage    = 'k'
+	pgStandbyStatusUpdateMessage = 'r'
+)
+
+// pgEpoch is the start of time of replication protocol timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) // nolint:gochecknoglobals
+
+// pgCdcDataSource streams row changes of logical replication slot,
+// optionally after rows of db data source read as snapshot.
+// LSN of transaction is confirmed to server only after all its changes and preceding ones are published,
+// so unpublished changes are streamed again after restart.
+type pgCdcDataSource struct {
+	stream         *pgReplicationStream
+	snapshot       *dataBaseSource
+	snapshotChange pgChange
+	logger         log.Logger
+	dataChan       chan domain.Payload
+	errChan        chan error
+	cancel         context.CancelFunc
+	stopped        chan struct{}
+
+	readCounter    *atomic.Uint64
+	isSnapshotRead *atomic.Bool
+}
+
+func NewPgCdc(ctx context.Context, cfg conf.PgCdcDataSource, logger log.Logger) (pgCdcDataSource, error) {
+	var decoder pgChangeDecoder
+	switch cfg.Plugin {
+	case "", pgoutputPlugin:
+		if len(cfg.Publications) == 0 {
+			return pgCdcDataSource{}, errors.New("publications are required for pgoutput plugin")
+		}
+		decoder = newPgoutputDecoder(cfg.Publications)
+	case wal2jsonPlugin:
+		decoder = wal2jsonDecoder{}
+	default:
+		return pgCdcDataSource{}, errors.Errorf("unsupported logical decoding plugin '%s'", cfg.Plugin)
+	}
+	plugin := cfg.Plugin
+	if plugin == "" {
+		plugin = pgoutputPlugin
+	}
+	if cfg.Snapshot != nil && !cfg.CreateSlot {
+		return pgCdcDataSource{}, errors.New("snapshot requires createSlot, as it is read only when slot is created")
+	}
+
+	conn, err := connectPgReplication(ctx, cfg)
+	if err != nil {
+		return pgCdcDataSource{}, errors.WithMessage(err, "connect to postgres in replication mode")
+	}
+	statusInterval := cfg.StatusInterval
+	if statusInterval <= 0 {
+		statusInterval = pgStatusIntervalInSec * time.Second
+	}
+	stream := &pgReplicationStream{
+		conn:           conn,
+		decoder:        decoder,
+		lsns:           newLsnTracker(),
+		statusInterval: statusInterval,
+	}
+
+	isSlotCreated := false
+	if cfg.CreateSlot {
+		isSlotCreated, err = stream.createSlot(ctx, cfg.Slot, plugin, logger)
+		if err != nil {
+			_ = conn.Close(ctx)
+			return pgCdcDataSource{}, errors.WithMessagef(err, "create replication slot '%s'", cfg.Slot)
+		}
+	}
+
+	dataSource := pgCdcDataSource{
+		stream:         stream,
+		logger:         logger,
+		dataChan:       make(chan domain.Payload),
+		errChan:        make(chan error, 1),
+		stopped:        make(chan struct{}),
+		readCounter:    new(atomic.Uint64),
+		isSnapshotRead: new(atomic.Bool),
+	}
+	// snapshot is read only along with new slot, otherwise its rows have already been published before restart
+	if cfg.Snapshot != nil && !isSlotCreated {
+		logger.Info(ctx, "snapshot is skipped, because replication slot already exists", log.String("slot", cfg.Slot))
+	}
+	if cfg.Snapshot != nil && isSlotCreated {
+		snapshotCfg := *cfg.Snapshot
+		snapshotCfg.Dialect = postgresDialectName
+		if snapshotCfg.Client.Host == "" {
+			snapshotCfg.Client = cfg.Client
+		}
+		snapshot, err := NewDataBase(ctx, snapshotCfg, logger, domain.Checkpoint{})
+		if err != nil {
+			_ = conn.Close(ctx)
+			return pgCdcDataSource{}, errors.WithMessage(err, "new snapshot db data source")
+		}
+		dataSource.snapshot = &snapshot
+		dataSource.snapshotChange = snapshotTableChange(snapshotCfg.Table)
+	}
+
+	err = stream.start(ctx, cfg.Slot)
+	if err != nil {
+		_ = dataSource.closeSnapshot(ctx)
+		_ = conn.Close(ctx)
+		return pgCdcDataSource{}, errors.WithMessagef(err, "start replication from slot '%s'", cfg.Slot)
+	}
+	logger.Info(ctx, "replication started", log.String("slot", cfg.Slot), log.String("plugin", plugin))
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	dataSource.cancel = cancel
+	go dataSource.startStreaming(streamCtx)
+
+	return dataSource, nil
+}
+
+func connectPgReplication(ctx context.Context, cfg conf.PgCdcDataSource) (*pgconn.PgConn, error) {
+	dsn := cfg.Dsn
+	if dsn == "" {
+		err := validator.Default.ValidateToError(cfg.Client)
+		if err != nil {
+			return nil, errors.WithMessage(err, "validate db client config")
+		}
+		dsn = cfg.Client.Dsn("")
+	}
+
+	connConfig, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse dsn")
+	}
+	connConfig.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, errors.WithMessage(err, "connect")
+	}
+	return conn, nil
+}
+
+// snapshotTableChange returns change with schema and table of snapshot table, default schema is used if it is not set.
+func snapshotTableChange(table string) pgChange {
+	change := pgChange{operation: pgSnapshotOperation, schema: pgDefaultSchema, table: table}
+	schema, name, ok := strings.Cut(table, ".")
+	if ok {
+		change.schema = schema
+		change.table = name
+	}
+	return change
+}
+
+func (s pgCdcDataSource) startStreaming(ctx context.Context) {
+	defer close(s.stopped)
+
+	err := s.stream.run(ctx, s.dataChan)
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		s.errChan <- err
+	default:
+		close(s.dataChan)
+	}
+}
+
+func (s pgCdcDataSource) GetData(ctx context.Context) (*domain.Payload, error) {
+	if s.snapshot != nil && !s.isSnapshotRead.Load() {
+		payload, err := s.snapshot.GetData(ctx)
+		switch {
+		case errors.Is(err, domain.ErrNoData):
+			s.isSnapshotRead.Store(true)
+			s.logger.Info(ctx, "snapshot is read", log.Any("count", s.readCounter.Load()))
+		case err != nil:
+			return nil, errors.WithMessage(err, "read snapshot")
+		default:
+			s.readCounter.Add(1)
+			change := s.snapshotChange
+			change.new, _ = payload.Data.(map[string]any)
+			return &domain.Payload{Data: change.data(nil), Metadata: payload.Metadata}, nil
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case v, ok := <-s.dataChan:
+		if !ok {
+			return nil, domain.ErrNoData
+		}
+		s.readCounter.Add(1)
+		return &v, nil
+	case err := <-s.errChan:
+		return nil, err
+	}
+}
+
+func (s pgCdcDataSource) Progress() domain.Progress {
+	return domain.Progress{
+		ReadDataCount:   s.readCounter.Load(),
+		ReadDataPercent: nil,
+	}
+}
+
+func (s pgCdcDataSource) Close(ctx context.Context) error {
+	s.cancel()
+	<-s.stopped
+
+	err := s.closeSnapshot(ctx)
+	if err != nil {
+		s.logger.Error(ctx, errors.WithMessage(err, "close snapshot db data source"))
+	}
+
+	err = s.stream.close(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "close replication stream")
+	}
+	return nil
+}
+
+func (s pgCdcDataSource) closeSnapshot(ctx context.Context) error {
+	if s.snapshot == nil {
+		return nil
+	}
+	return s.snapshot.Close(ctx)
+}
+
+// pgReplicationStream receives messages of logical replication protocol and sends status updates with confirmed LSN,
+// connection is used by single goroutine.
+type pgReplicationStream struct {
+	conn           *pgconn.PgConn
+	decoder        pgChangeDecoder
+	lsns           *lsnTracker
+	statusInterval time.Duration
+	statusDeadline time.Time
+	isInTx         bool
+}
+
+// createSlot creates slot if it does not exist and reports whether it is created.
+func (s *pgReplicationStream) createSlot(ctx context.Context, slot string, plugin string, logger log.Logger) (bool, error) {
+	query := fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL %s NOEXPORT_SNAPSHOT", pgIdentifier(slot), plugin)
+	_, err := s.conn.Exec(ctx, query).ReadAll()
+	pgErr := new(pgconn.PgError)
+	if errors.As(err, &pgErr) && pgErr.Code == pgDuplicateObjectCode {
+		logger.Info(ctx, "replication slot already exists", log.String("slot", slot))
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithMessage(err, "exec create replication slot")
+	}
+	logger.Info(ctx, "replication slot created", log.String("slot", slot))
+	return true, nil
+}
+
+// start starts streaming from confirmed LSN of slot.
+func (s *pgReplicationStream) start(ctx context.Context, slot string) error {
+	query := fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL 0/0 (%s)",
+		pgIdentifier(slot), strings.Join(s.decoder.pluginOptions(), ", "))
+	s.conn.Frontend().SendQuery(&pgproto3.Query{String: query})
+	err := s.conn.Frontend().Flush()
+	if err != nil {
+		return errors.WithMessage(err, "send start replication")
+	}
+
+	for {
+		msg, err := s.conn.ReceiveMessage(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "receive start replication response")
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			s.statusDeadline = time.Now().Add(s.statusInterval)
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		}
+	}
+}
+
+func (s *pgReplicationStream) run(ctx context.Context, dataChan chan<- domain.Payload) error {
+	for {
+		if !time.Now().Before(s.statusDeadline) {
+			err := s.sendStatus()
+			if err != nil {
+				return err
+			}
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, s.statusDeadline)
+		msg, err := s.conn.ReceiveMessage(receiveCtx)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case pgconn.Timeout(err):
+			continue
+		case err != nil:
+			return errors.WithMessage(err, "receive replication message")
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			err = s.handleCopyData(ctx, msg.Data, dataChan)
+			if err != nil {
+				return err
+			}
+		case *pgproto3.ErrorResponse:
+			return errors.WithMessage(pgconn.ErrorResponseToPgError(msg), "replication stream")
+		case *pgproto3.CopyDone:
+			return nil
+		}
+	}
+}
+
+func (s *pgReplicationStream) handleCopyData(ctx context.Context, data []byte, dataChan chan<- domain.Payload) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	r := &pgMessageReader{data: data[1:]}
+	switch data[0] {
+	case pgPrimaryKeepaliveMessage:
+		walEnd := r.uint64()
+		r.uint64() // server time
+		isReplyRequested := r.uint8() == 1
+		err := r.err()
+		if err != nil {
+			return errors.WithMessage(err, "read keepalive message")
+		}
+		if !s.isInTx {
+			s.lsns.track(walEnd)()
+		}
+		if isReplyRequested {
+			return s.sendStatus()
+		}
+	case pgXLogDataMessage:
+		walStart := r.uint64()
+		r.uint64() // wal end
+		r.uint64() // server time
+		message := r.rest()
+		err := r.err()
+		if err != nil {
+			return errors.WithMessage(err, "read xlog data message")
+		}
+
+		kind, change, err := s.decoder.decode(message)
+		if err != nil {
+			return errors.WithMessagef(err, "decode message at lsn %s", formatLsn(walStart))
+		}
+		switch kind {
+		case pgBeginMessage:
+			s.isInTx = true
+		case pgCommitMessage:
+			s.isInTx = false
+			// lsn of commit message is the end of transaction
+			s.lsns.track(walStart)()
+		case pgChangeMessage:
+			return s.emit(ctx, dataChan, domain.Payload{
+				Data:         change.data(formatLsn(walStart)),
+				Acknowledger: lsnAcknowledger{commit: s.lsns.track(0)},
+			})
+		case pgOtherMessage:
+		}
+	}
+	return nil
+}
+
+// emit waits for change to be read, sending status updates in the meantime.
+func (s *pgReplicationStream) emit(ctx context.Context, dataChan chan<- domain.Payload, payload domain.Payload) error {
+	for {
+		select {
+		case dataChan <- payload:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(s.statusDeadline)):
+			err := s.sendStatus()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendStatus sends standby status update, confirmed LSN is reported as written, flushed and applied.
+func (s *pgReplicationStream) sendStatus() error {
+	lsn := s.lsns.confirmed()
+	data := make([]byte, 0, 34) // nolint:mnd
+	data = append(data, pgStandbyStatusUpdateMessage)
+	data = binary.BigEndian.AppendUint64(data, lsn)
+	data = binary.BigEndian.AppendUint64(data, lsn)
+	data = binary.BigEndian.AppendUint64(data, lsn)
+	data = binary.BigEndian.AppendUint64(data, uint64(time.Since(pgEpoch).Microseconds())) // nolint:gosec
+	data = append(data, 0)
+
+	s.conn.Frontend().Send(&pgproto3.CopyData{Data: data})
+	err := s.conn.Frontend().Flush()
+	if err != nil {
+		return errors.WithMessage(err, "send standby status update")
+	}
+	s.statusDeadline = time.Now().Add(s.statusInterval)
+	return nil
+}
+
+func (s *pgReplicationStream) close(ctx context.Context) error {
+	statusErr := s.sendStatus()
+	err := s.conn.Close(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "close connection")
+	}
+	if statusErr != nil {
+		return errors.WithMessagef(statusErr, "confirm lsn %s", formatLsn(s.lsns.confirmed()))
+	}
+	return nil
+}
+
+// lsnTracker confirms LSN in order of tracking, LSN is confirmed when all preceding tracked items are done.
+// Zero LSN is tracked for changes, which only hold back LSN of transaction commit.
+type lsnTracker struct {
+	lock         *sync.Mutex
+	nextSeq      uint64
+	committedSeq uint64
+	lsns         map[uint64]uint64
+	doneSeqs     map[uint64]struct{}
+	confirmedLsn uint64
+}
+
+func newLsnTracker() *lsnTracker {
+	return &lsnTracker{
+		lock:     new(sync.Mutex),
+		lsns:     make(map[uint64]uint64),
+		doneSeqs: make(map[uint64]struct{}),
+	}
+}
+
+func (t *lsnTracker) track(lsn uint64) func() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	seq := t.nextSeq
+	t.nextSeq++
+	t.lsns[seq] = lsn
+
+	return func() { t.commit(seq) }
+}
+
+func (t *lsnTracker) commit(seq uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.doneSeqs[seq] = struct{}{}
+	for {
+		_, ok := t.doneSeqs[t.committedSeq]
+		if !ok {
+			return
+		}
+		t.confirmedLsn = max(t.confirmedLsn, t.lsns[t.committedSeq])
+		delete(t.doneSeqs, t.committedSeq)
+		delete(t.lsns, t.committedSeq)
+		t.committedSeq++
+	}
+}
+
+func (t *lsnTracker) confirmed() uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.confirmedLsn
+}
+
+type lsnAcknowledger struct {
+	commit func()
+}
+
+func (a lsnAcknowledger) Ack() error {
+	a.commit()
+	return nil
+}
+
+// Nack leaves LSN of transaction unconfirmed, so the change is streamed again after restart.
+func (a lsnAcknowledger) Nack() error {
+	return nil
+}
+
+func formatLsn(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn)) // nolint:gosec,mnd
+}
+
+func pgIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func pgLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
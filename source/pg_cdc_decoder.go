@@ -0,0 +1,413 @@
+package source
+
+import (
+	"bytes"
+	"encoding/binary"
+	stdjson "encoding/json"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	pgInsertOperation   = "insert"
+	pgUpdateOperation   = "update"
+	pgDeleteOperation   = "delete"
+	pgSnapshotOperation = "snapshot"
+)
+
+type pgMessageKind int
+
+const (
+	pgOtherMessage pgMessageKind = iota
+	pgBeginMessage
+	pgChangeMessage
+	pgCommitMessage
+)
+
+// pgChange is row change of a table, old values are set for update and delete
+// according to replica identity of the table.
+type pgChange struct {
+	operation string
+	schema    string
+	table     string
+	old       map[string]any
+	new       map[string]any
+}
+
+func (c pgChange) data(lsn any) map[string]any {
+	return map[string]any{
+		"operation": c.operation,
+		"schema":    c.schema,
+		"table":     c.table,
+		"old":       c.old,
+		"new":       c.new,
+		"lsn":       lsn,
+	}
+}
+
+// pgChangeDecoder decodes messages of logical decoding output plugin.
+type pgChangeDecoder interface {
+	pluginOptions() []string
+	decode(data []byte) (pgMessageKind, pgChange, error)
+}
+
+type pgRelation struct {
+	schema  string
+	table   string
+	columns []pgColumn
+}
+
+type pgColumn struct {
+	name    string
+	typeOid uint32
+	isKey   bool
+}
+
+// pgoutputDecoder decodes messages of protocol version 1 of pgoutput plugin.
+// Values are sent in text format and converted according to column types,
+// relation messages describing columns precede the first change of every table.
+type pgoutputDecoder struct {
+	publications []string
+	typeMap      *pgtype.Map
+	relations    map[uint32]pgRelation
+}
+
+func newPgoutputDecoder(publications []string) pgoutputDecoder {
+	return pgoutputDecoder{
+		publications: publications,
+		typeMap:      pgtype.NewMap(),
+		relations:    make(map[uint32]pgRelation),
+	}
+}
+
+func (d pgoutputDecoder) pluginOptions() []string {
+	names := make([]string, len(d.publications))
+	for i, publication := range d.publications {
+		names[i] = pgIdentifier(publication)
+	}
+	return []string{
+		"proto_version '1'",
+		"publication_names " + pgLiteral(strings.Join(names, ",")),
+	}
+}
+
+// nolint:cyclop
+func (d pgoutputDecoder) decode(data []byte) (pgMessageKind, pgChange, error) {
+	if len(data) == 0 {
+		return pgOtherMessage, pgChange{}, errors.New("empty pgoutput message")
+	}
+	r := &pgMessageReader{data: data[1:]}
+	switch data[0] {
+	case 'B':
+		return pgBeginMessage, pgChange{}, nil
+	case 'C':
+		return pgCommitMessage, pgChange{}, nil
+	case 'R':
+		return pgOtherMessage, pgChange{}, d.readRelation(r)
+	case 'I':
+		relation, err := d.relation(r.uint32())
+		if err != nil {
+			return pgOtherMessage, pgChange{}, err
+		}
+		change := pgChange{operation: pgInsertOperation, schema: relation.schema, table: relation.table}
+		if r.uint8() != 'N' {
+			return pgOtherMessage, pgChange{}, errors.New("insert message has no new tuple")
+		}
+		change.new, err = d.readTuple(r, relation, false)
+		return pgChangeMessage, change, err
+	case 'U':
+		relation, err := d.relation(r.uint32())
+		if err != nil {
+			return pgOtherMessage, pgChange{}, err
+		}
+		change := pgChange{operation: pgUpdateOperation, schema: relation.schema, table: relation.table}
+		tupleKind := r.uint8()
+		if tupleKind == 'K' || tupleKind == 'O' {
+			change.old, err = d.readTuple(r, relation, tupleKind == 'K')
+			if err != nil {
+				return pgOtherMessage, pgChange{}, err
+			}
+			tupleKind = r.uint8()
+		}
+		if tupleKind != 'N' {
+			return pgOtherMessage, pgChange{}, errors.New("update message has no new tuple")
+		}
+		change.new, err = d.readTuple(r, relation, false)
+		return pgChangeMessage, change, err
+	case 'D':
+		relation, err := d.relation(r.uint32())
+		if err != nil {
+			return pgOtherMessage, pgChange{}, err
+		}
+		change := pgChange{operation: pgDeleteOperation, schema: relation.schema, table: relation.table}
+		tupleKind := r.uint8()
+		if tupleKind != 'K' && tupleKind != 'O' {
+			return pgOtherMessage, pgChange{}, errors.New("delete message has no old tuple")
+		}
+		change.old, err = d.readTuple(r, relation, tupleKind == 'K')
+		return pgChangeMessage, change, err
+	default:
+		return pgOtherMessage, pgChange{}, nil
+	}
+}
+
+func (d pgoutputDecoder) readRelation(r *pgMessageReader) error {
+	relationId := r.uint32()
+	relation := pgRelation{
+		schema: r.cstring(),
+		table:  r.cstring(),
+	}
+	r.uint8() // replica identity setting
+	columnsCount := int(r.uint16())
+	for range columnsCount {
+		if r.isShort {
+			break
+		}
+		flags := r.uint8()
+		relation.columns = append(relation.columns, pgColumn{
+			name:    r.cstring(),
+			typeOid: r.uint32(),
+			isKey:   flags&1 != 0,
+		})
+		r.uint32() // type modifier
+	}
+	err := r.err()
+	if err != nil {
+		return errors.WithMessage(err, "read relation message")
+	}
+	d.relations[relationId] = relation
+	return nil
+}
+
+func (d pgoutputDecoder) relation(relationId uint32) (pgRelation, error) {
+	relation, ok := d.relations[relationId]
+	if !ok {
+		return pgRelation{}, errors.Errorf("unknown relation %d", relationId)
+	}
+	return relation, nil
+}
+
+// readTuple reads column values, only key columns are returned for key tuple.
+// Unchanged TOASTed values are not sent by server, so they are omitted.
+func (d pgoutputDecoder) readTuple(r *pgMessageReader, relation pgRelation, isKeyTuple bool) (map[string]any, error) {
+	columnsCount := int(r.uint16())
+	if columnsCount > len(relation.columns) {
+		return nil, errors.Errorf("tuple has %d columns, relation '%s.%s' has %d", columnsCount, relation.schema, relation.table, len(relation.columns))
+	}
+
+	tuple := make(map[string]any, columnsCount)
+	for _, column := range relation.columns[:columnsCount] {
+		kind := r.uint8()
+		if r.isShort {
+			break
+		}
+		var value any
+		switch kind {
+		case 'n':
+		case 'u':
+			continue
+		case 't':
+			text := r.next(int(r.uint32()))
+			if r.isShort {
+				break
+			}
+			var err error
+			value, err = pgTextValue(d.typeMap, column.typeOid, text)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "column '%s' of relation '%s.%s'", column.name, relation.schema, relation.table)
+			}
+		default:
+			return nil, errors.Errorf("unsupported kind '%c' of column '%s' value", kind, column.name)
+		}
+		if isKeyTuple && !column.isKey {
+			continue
+		}
+		tuple[column.name] = value
+	}
+
+	err := r.err()
+	if err != nil {
+		return nil, errors.WithMessage(err, "read tuple")
+	}
+	return tuple, nil
+}
+
+// pgTextValue converts value in text format to go value for numbers, booleans, json, dates, bytea and arrays of them.
+// Values of other types, e.g. numeric, uuid or interval, remain strings to not lose precision.
+func pgTextValue(typeMap *pgtype.Map, typeOid uint32, text []byte) (any, error) {
+	switch typeOid {
+	case pgtype.BoolOID, pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID, pgtype.Float4OID, pgtype.Float8OID,
+		pgtype.JSONOID, pgtype.JSONBOID, pgtype.DateOID, pgtype.TimestampOID, pgtype.TimestamptzOID, pgtype.ByteaOID,
+		pgtype.BoolArrayOID, pgtype.Int2ArrayOID, pgtype.Int4ArrayOID, pgtype.Int8ArrayOID, pgtype.Float4ArrayOID,
+		pgtype.Float8ArrayOID, pgtype.TextArrayOID, pgtype.VarcharArrayOID, pgtype.JSONBArrayOID:
+	default:
+		return string(text), nil
+	}
+
+	pgType, ok := typeMap.TypeForOID(typeOid)
+	if !ok {
+		return string(text), nil
+	}
+	value, err := pgType.Codec.DecodeValue(typeMap, typeOid, pgtype.TextFormatCode, text)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "decode value of type '%s'", pgType.Name)
+	}
+	return value, nil
+}
+
+// wal2jsonDecoder decodes messages of format version 2 of wal2json plugin, one message per change.
+type wal2jsonDecoder struct{}
+
+type wal2jsonMessage struct {
+	Action   string           `json:"action"`
+	Schema   string           `json:"schema"`
+	Table    string           `json:"table"`
+	Columns  []wal2jsonColumn `json:"columns"`
+	Identity []wal2jsonColumn `json:"identity"`
+}
+
+type wal2jsonColumn struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+func (d wal2jsonDecoder) pluginOptions() []string {
+	return []string{
+		pgIdentifier("format-version") + " '2'",
+	}
+}
+
+func (d wal2jsonDecoder) decode(data []byte) (pgMessageKind, pgChange, error) {
+	message := wal2jsonMessage{}
+	// numbers are decoded as json.Number, so values of bigint and numeric columns do not lose precision
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&message)
+	if err != nil {
+		return pgOtherMessage, pgChange{}, errors.WithMessage(err, "unmarshal wal2json message")
+	}
+
+	change := pgChange{schema: message.Schema, table: message.Table}
+	switch message.Action {
+	case "B":
+		return pgBeginMessage, pgChange{}, nil
+	case "C":
+		return pgCommitMessage, pgChange{}, nil
+	case "I":
+		change.operation = pgInsertOperation
+		change.new = wal2jsonTuple(message.Columns)
+	case "U":
+		change.operation = pgUpdateOperation
+		change.old = wal2jsonTuple(message.Identity)
+		change.new = wal2jsonTuple(message.Columns)
+	case "D":
+		change.operation = pgDeleteOperation
+		change.old = wal2jsonTuple(message.Identity)
+	default:
+		return pgOtherMessage, pgChange{}, nil
+	}
+	return pgChangeMessage, change, nil
+}
+
+func wal2jsonTuple(columns []wal2jsonColumn) map[string]any {
+	if columns == nil {
+		return nil
+	}
+	tuple := make(map[string]any, len(columns))
+	for _, column := range columns {
+		tuple[column.Name] = wal2jsonValue(column.Value)
+	}
+	return tuple
+}
+
+// wal2jsonValue converts integer to int64, other numbers remain json.Number,
+// which is marshaled as the same number.
+func wal2jsonValue(value any) any {
+	number, ok := value.(stdjson.Number)
+	if !ok {
+		return value
+	}
+	intValue, err := number.Int64()
+	if err != nil {
+		return number
+	}
+	return intValue
+}
+
+// pgMessageReader reads big-endian values of replication protocol messages,
+// reading beyond the end of message returns zero values and is reported by err.
+type pgMessageReader struct {
+	data    []byte
+	isShort bool
+}
+
+func (r *pgMessageReader) next(n int) []byte {
+	if n < 0 || len(r.data) < n {
+		r.isShort = true
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *pgMessageReader) uint8() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *pgMessageReader) uint16() uint16 {
+	b := r.next(2) // nolint:mnd
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *pgMessageReader) uint32() uint32 {
+	b := r.next(4) // nolint:mnd
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *pgMessageReader) uint64() uint64 {
+	b := r.next(8) // nolint:mnd
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *pgMessageReader) cstring() string {
+	idx := bytes.IndexByte(r.data, 0)
+	if idx < 0 {
+		r.isShort = true
+		r.data = nil
+		return ""
+	}
+	s := string(r.data[:idx])
+	r.data = r.data[idx+1:]
+	return s
+}
+
+func (r *pgMessageReader) rest() []byte {
+	b := r.data
+	r.data = nil
+	return b
+}
+
+func (r *pgMessageReader) err() error {
+	if r.isShort {
+		return errors.New("unexpected end of message")
+	}
+	return nil
+}
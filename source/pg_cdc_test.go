@@ -0,0 +1,66 @@
+package source
+
+import (
+	"testing"
+)
+
+func TestLsnTrackerConfirmed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		lsns        []uint64
+		commitOrder []int
+		// expected confirmed LSN after each commit
+		expected []uint64
+	}{
+		{
+			name:        "in order",
+			lsns:        []uint64{100, 200, 300},
+			commitOrder: []int{0, 1, 2},
+			expected:    []uint64{100, 200, 300},
+		},
+		{
+			name:        "reverse order",
+			lsns:        []uint64{100, 200, 300},
+			commitOrder: []int{2, 1, 0},
+			expected:    []uint64{0, 0, 300},
+		},
+		{
+			name:        "pending change holds back commit of transaction",
+			lsns:        []uint64{0, 0, 500},
+			commitOrder: []int{2, 0, 1},
+			expected:    []uint64{0, 0, 500},
+		},
+		{
+			name:        "confirmed lsn never decreases",
+			lsns:        []uint64{300, 0, 200},
+			commitOrder: []int{0, 1, 2},
+			expected:    []uint64{300, 300, 300},
+		},
+		{
+			name:        "gap is filled later",
+			lsns:        []uint64{100, 200, 300, 400},
+			commitOrder: []int{0, 3, 2, 1},
+			expected:    []uint64{100, 100, 100, 400},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := newLsnTracker()
+			commits := make([]func(), 0, len(test.lsns))
+			for _, lsn := range test.lsns {
+				commits = append(commits, tracker.track(lsn))
+			}
+			for i, idx := range test.commitOrder {
+				commits[idx]()
+				confirmed := tracker.confirmed()
+				if confirmed != test.expected[i] {
+					t.Fatalf("after commit of %d: expected lsn %s, got %s", idx, formatLsn(test.expected[i]), formatLsn(confirmed))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/validator"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
+)
+
+// pgNotifyDataSource relays payloads of notifications sent by NOTIFY or pg_notify to listened channels.
+// Notifications are received by single connection, so they are not stored and are lost while it is closed.
+type pgNotifyDataSource struct {
+	conn     *pgconn.PgConn
+	logger   log.Logger
+	received *[]*pgconn.Notification
+	dataChan chan *pgconn.Notification
+	errChan  chan error
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+
+	readCounter     *atomic.Uint64
+	consumeTimeout  time.Duration
+	requestIdField  string
+	isPlainTextMode bool
+}
+
+func NewPgNotify(ctx context.Context, cfg conf.PgNotifyDataSource, logger log.Logger, isPlainTextMode bool) (pgNotifyDataSource, error) {
+	err := validator.Default.ValidateToError(cfg.Client)
+	if err != nil {
+		return pgNotifyDataSource{}, errors.WithMessage(err, "validate db client config")
+	}
+	connConfig, err := pgconn.ParseConfig(cfg.Client.Dsn(""))
+	if err != nil {
+		return pgNotifyDataSource{}, errors.WithMessage(err, "parse dsn")
+	}
+	// notification handler is called by connection methods, so received notifications are used by the same goroutine
+	received := new([]*pgconn.Notification)
+	connConfig.OnNotification = func(_ *pgconn.PgConn, notification *pgconn.Notification) {
+		*received = append(*received, notification)
+	}
+	conn, err := pgconn.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return pgNotifyDataSource{}, errors.WithMessage(err, "connect to postgres")
+	}
+
+	queries := make([]string, len(cfg.Channels))
+	for i, channel := range cfg.Channels {
+		queries[i] = "LISTEN " + pgIdentifier(channel)
+	}
+	_, err = conn.Exec(ctx, strings.Join(queries, "; ")).ReadAll()
+	if err != nil {
+		_ = conn.Close(ctx)
+		return pgNotifyDataSource{}, errors.WithMessage(err, "listen channels")
+	}
+	logger.Info(ctx, "listening to channels", log.Any("channels", cfg.Channels))
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	dataSource := pgNotifyDataSource{
+		conn:            conn,
+		logger:          logger,
+		received:        received,
+		dataChan:        make(chan *pgconn.Notification),
+		errChan:         make(chan error, 1),
+		cancel:          cancel,
+		stopped:         make(chan struct{}),
+		readCounter:     new(atomic.Uint64),
+		consumeTimeout:  cfg.ConsumeTimeout,
+		requestIdField:  cfg.RequestIdField,
+		isPlainTextMode: isPlainTextMode,
+	}
+	go dataSource.listen(listenCtx)
+
+	return dataSource, nil
+}
+
+func (s pgNotifyDataSource) listen(ctx context.Context) {
+	defer close(s.stopped)
+
+	for {
+		notifications := *s.received
+		*s.received = nil
+		for _, notification := range notifications {
+			select {
+			case s.dataChan <- notification:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		err := s.conn.WaitForNotification(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			s.errChan <- errors.WithMessage(err, "wait for notification")
+			return
+		}
+	}
+}
+
+func (s pgNotifyDataSource) GetData(ctx context.Context) (*domain.Payload, error) {
+	var timeout <-chan time.Time
+	if s.consumeTimeout > 0 {
+		timeout = time.After(s.consumeTimeout)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case notification := <-s.dataChan:
+		s.readCounter.Add(1)
+		return s.payload(notification)
+	case err := <-s.errChan:
+		return nil, err
+	case <-timeout:
+		s.logger.Info(ctx, "consume timeout")
+		return nil, domain.ErrNoData
+	}
+}
+
+func (s pgNotifyDataSource) payload(notification *pgconn.Notification) (*domain.Payload, error) {
+	bytes := []byte(notification.Payload)
+	if s.isPlainTextMode {
+		return &domain.Payload{Data: bytes, Raw: bytes}, nil
+	}
+
+	var data any
+	err := json.Unmarshal(bytes, &data)
+	if err != nil {
+		return nil, domain.NewRecordError(
+			errors.WithMessagef(err, "unmarshal payload of notification from channel '%s'", notification.Channel),
+			bytes, nil,
+		)
+	}
+	payload := &domain.Payload{Data: data, Raw: bytes}
+	requestId, ok := utils.LookupField(data, s.requestIdField)
+	if ok {
+		payload.RequestId = utils.FieldString(requestId)
+	}
+	return payload, nil
+}
+
+func (s pgNotifyDataSource) Progress() domain.Progress {
+	return domain.Progress{
+		ReadDataCount:   s.readCounter.Load(),
+		ReadDataPercent: nil,
+	}
+}
+
+func (s pgNotifyDataSource) Close(ctx context.Context) error {
+	s.cancel()
+	<-s.stopped
+
+	err := s.conn.Close(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "close connection")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package source
 
 import (
 	"context"
+	"maps"
 	"sync/atomic"
 	"time"
 
@@ -22,21 +23,27 @@ type rabbitMqDataSource struct {
 	logger   log.Logger
 	dataChan chan domain.Payload
 	errChan  chan error
+	done     chan struct{}
 
-	readCounter     *atomic.Uint64
-	consumeTimeout  time.Duration
-	isPlainTextMode bool
+	readCounter       *atomic.Uint64
+	consumeTimeout    time.Duration
+	isPlainTextMode   bool
+	isAckAfterPublish bool
+	isRequeueOnError  bool
 }
 
 func NewRabbitMq(ctx context.Context, cfg conf.RabbitMqDataSource, logger log.Logger, isPlainTextMode bool) (rabbitMqDataSource, error) {
 	dataSource := rabbitMqDataSource{
-		cli:             grmqx.New(logger),
-		logger:          logger,
-		dataChan:        make(chan domain.Payload),
-		errChan:         make(chan error),
-		readCounter:     new(atomic.Uint64),
-		consumeTimeout:  consumeTimeoutInSec * time.Second,
-		isPlainTextMode: isPlainTextMode,
+		cli:               grmqx.New(logger),
+		logger:            logger,
+		dataChan:          make(chan domain.Payload),
+		errChan:           make(chan error),
+		done:              make(chan struct{}),
+		readCounter:       new(atomic.Uint64),
+		consumeTimeout:    consumeTimeoutInSec * time.Second,
+		isPlainTextMode:   isPlainTextMode,
+		isAckAfterPublish: cfg.AckAfterPublish,
+		isRequeueOnError:  cfg.RequeueOnError,
 	}
 	if cfg.ConsumeTimeout > 0 {
 		dataSource.consumeTimeout = cfg.ConsumeTimeout
@@ -56,6 +63,8 @@ func NewRabbitMq(ctx context.Context, cfg conf.RabbitMqDataSource, logger log.Lo
 
 func (r rabbitMqDataSource) GetData(ctx context.Context) (*domain.Payload, error) {
 	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case v, ok := <-r.dataChan:
 		if !ok {
 			return nil, domain.ErrNoData
@@ -79,33 +88,107 @@ func (r rabbitMqDataSource) Progress() domain.Progress {
 }
 
 func (r rabbitMqDataSource) Handle(ctx context.Context, delivery *consumer.Delivery) {
-	bytes := delivery.Source().Body
+	source := delivery.Source()
+	bytes := source.Body
 	payload := domain.Payload{
 		RequestId: requestid.FromContext(ctx),
 		Data:      bytes,
+		Raw:       bytes,
+		Metadata: &domain.Metadata{
+			Headers:         maps.Clone(source.Headers),
+			ContentType:     source.ContentType,
+			ContentEncoding: source.ContentEncoding,
+			Priority:        source.Priority,
+			CorrelationId:   source.CorrelationId,
+			ReplyTo:         source.ReplyTo,
+			Expiration:      source.Expiration,
+			MessageId:       source.MessageId,
+			Timestamp:       source.Timestamp,
+			Type:            source.Type,
+			UserId:          source.UserId,
+			AppId:           source.AppId,
+		},
+	}
+	if payload.Metadata.Headers == nil {
+		payload.Metadata.Headers = make(map[string]any)
 	}
 	if !r.isPlainTextMode {
 		var data any
 		err := json.Unmarshal(bytes, &data)
 		if err != nil {
-			r.errChan <- errors.WithMessagef(err, "unmarshal delivery body; request id = %s", payload.RequestId)
-			err = delivery.Retry()
-			if err != nil {
-				r.errChan <- errors.WithMessagef(err, "retry delivery; request id = %s", payload.RequestId)
+			// delivery is acked after record is rejected
+			recordErr := domain.NewRecordError(
+				errors.WithMessagef(err, "unmarshal delivery body; request id = %s", payload.RequestId),
+				bytes, nil,
+			)
+			recordErr.Acknowledger = deliveryAcknowledger{delivery: delivery, isRequeue: r.isRequeueOnError}
+			select {
+			case r.errChan <- recordErr:
+			case <-r.done:
+				err := delivery.Nack(true)
+				if err != nil {
+					r.logger.Error(ctx, errors.WithMessagef(err, "nack unread delivery; request id = %s", payload.RequestId))
+				}
 			}
 			return
 		}
 		payload.Data = data
 	}
 
-	r.dataChan <- payload
+	if r.isAckAfterPublish {
+		payload.Acknowledger = deliveryAcknowledger{delivery: delivery, isRequeue: r.isRequeueOnError}
+	}
+	select {
+	case r.dataChan <- payload:
+	case <-r.done:
+		err := delivery.Nack(true)
+		if err != nil {
+			r.logger.Error(ctx, errors.WithMessagef(err, "nack unread delivery; request id = %s", payload.RequestId))
+		}
+		return
+	}
+	if r.isAckAfterPublish {
+		return
+	}
+
 	err := delivery.Ack()
 	if err != nil {
-		r.errChan <- errors.WithMessagef(err, "ack delivery; request id = %s", payload.RequestId)
+		r.sendErr(errors.WithMessagef(err, "ack delivery; request id = %s", payload.RequestId))
+	}
+}
+
+func (r rabbitMqDataSource) sendErr(err error) {
+	select {
+	case r.errChan <- err:
+	case <-r.done:
 	}
 }
 
 func (r rabbitMqDataSource) Close(_ context.Context) error {
+	close(r.done)
 	r.cli.Close()
 	return nil
 }
+
+type deliveryAcknowledger struct {
+	delivery  *consumer.Delivery
+	isRequeue bool
+}
+
+func (d deliveryAcknowledger) Ack() error {
+	err := d.delivery.Ack()
+	if err != nil {
+		return errors.WithMessage(err, "ack delivery")
+	}
+	return nil
+}
+
+// Nack returns delivery to the queue only if isRequeue is set,
+// otherwise it is dropped or dead-lettered, so a failing message does not loop.
+func (d deliveryAcknowledger) Nack() error {
+	err := d.delivery.Nack(d.isRequeue)
+	if err != nil {
+		return errors.WithMessage(err, "nack delivery")
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/mqpusher/conf"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxDataSource streams rows of a single sheet of xlsx workbook.
+// The first row of the cell range (or, without range, the first non-empty row of the sheet) is the header,
+// the columns of data are its cells.
+type xlsxDataSource struct {
+	workbook  *excelize.File
+	rows      *excelize.Rows
+	filePath  string
+	sheet     string
+	columns   []string
+	cellRange cellRange
+
+	readCounter *atomic.Uint64
+	rowNum      *atomic.Int64
+	headerRow   int64
+	lastRow     int64
+}
+
+func NewXlsx(cfg conf.XlsxDataSource, checkpoint domain.Checkpoint) (xlsxDataSource, error) {
+	cellRange, err := parseCellRange(cfg.Range)
+	if err != nil {
+		return xlsxDataSource{}, errors.WithMessagef(err, "parse cell range '%s'", cfg.Range)
+	}
+
+	inputFile, err := utils.OpenInputFile(cfg.FilePath, 0)
+	if err != nil {
+		return xlsxDataSource{}, errors.WithMessage(err, "open input file")
+	}
+	workbook, err := excelize.OpenReader(inputFile)
+	_ = inputFile.Close()
+	if err != nil {
+		return xlsxDataSource{}, errors.WithMessagef(err, "open xlsx workbook '%s'", cfg.FilePath)
+	}
+
+	dataSource := xlsxDataSource{
+		workbook:    workbook,
+		filePath:    cfg.FilePath,
+		cellRange:   cellRange,
+		readCounter: new(atomic.Uint64),
+		rowNum:      new(atomic.Int64),
+	}
+	dataSource, err = dataSource.open(cfg, checkpoint.RowNums[0])
+	if err != nil {
+		_ = dataSource.Close(context.Background())
+		return xlsxDataSource{}, err
+	}
+	return dataSource, nil
+}
+
+// open returns data source positioned after resumeRow with selected sheet, its header and rows iterator.
+// On error the returned data source still holds opened resources to close.
+func (x xlsxDataSource) open(cfg conf.XlsxDataSource, resumeRow int64) (xlsxDataSource, error) {
+	var err error
+	x.sheet, err = xlsxSheet(x.workbook, cfg.Sheet, cfg.SheetIndex)
+	if err != nil {
+		return x, err
+	}
+
+	x.lastRow, err = countSheetRows(x.workbook, x.sheet)
+	if err != nil {
+		return x, errors.WithMessagef(err, "count rows of sheet '%s'", x.sheet)
+	}
+	if x.cellRange.toRow > 0 {
+		x.lastRow = min(x.lastRow, x.cellRange.toRow)
+	}
+
+	x.rows, err = x.workbook.Rows(x.sheet)
+	if err != nil {
+		return x, errors.WithMessagef(err, "read rows of sheet '%s'", x.sheet)
+	}
+	x.columns, x.headerRow, err = x.readHeader()
+	if err != nil {
+		return x, errors.WithMessagef(err, "read header of sheet '%s'", x.sheet)
+	}
+
+	for x.rowNum.Load() < resumeRow && x.rows.Next() {
+		x.rowNum.Add(1)
+	}
+	return x, nil
+}
+
+func xlsxSheet(workbook *excelize.File, name string, index int) (string, error) {
+	sheets := workbook.GetSheetList()
+	if name != "" {
+		if !slices.Contains(sheets, name) {
+			return "", errors.Errorf("sheet '%s' not found, available sheets: %v", name, sheets)
+		}
+		return name, nil
+	}
+	if index >= len(sheets) {
+		return "", errors.Errorf("sheet index %d is out of range, workbook has %d sheets", index, len(sheets))
+	}
+	return sheets[index], nil
+}
+
+// countSheetRows returns the number of the last row of sheet without reading its cells.
+func countSheetRows(workbook *excelize.File, sheet string) (int64, error) {
+	rows, err := workbook.Rows(sheet)
+	if err != nil {
+		return 0, errors.WithMessage(err, "read rows")
+	}
+	count := int64(0)
+	for rows.Next() {
+		count++
+	}
+	err = rows.Error()
+	if err != nil {
+		return 0, errors.WithMessage(err, "iterate rows")
+	}
+	return count, rows.Close()
+}
+
+// readHeader returns unique column names and number of header row.
+func (x xlsxDataSource) readHeader() ([]string, int64, error) {
+	for x.rows.Next() {
+		rowNum := x.rowNum.Add(1)
+		if rowNum < x.cellRange.fromRow {
+			continue
+		}
+		if x.cellRange.toRow > 0 && rowNum > x.cellRange.toRow {
+			break
+		}
+
+		row, err := x.rows.Columns()
+		if err != nil {
+			return nil, 0, errors.WithMessagef(err, "read row %d", rowNum)
+		}
+		row = x.cellRange.cells(row)
+		if x.cellRange.toRow == 0 && isEmptyRow(row) {
+			continue
+		}
+
+		columns := make([]string, 0, len(row))
+		for i, cell := range row {
+			column := strings.TrimSpace(cell)
+			if column == "" {
+				column, err = excelize.ColumnNumberToName(x.cellRange.fromCol + i)
+				if err != nil {
+					return nil, 0, errors.WithMessage(err, "define column name")
+				}
+			}
+			columns = append(columns, column)
+		}
+		return uniqueColumns(columns), rowNum, nil
+	}
+	err := x.rows.Error()
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "iterate rows")
+	}
+	return nil, 0, errors.New("header row not found")
+}
+
+// uniqueColumns adds suffix '_2', '_3', etc. to repeated column names, so values of columns are not overwritten.
+func uniqueColumns(columns []string) []string {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		seen[column] = true
+	}
+	result := make([]string, 0, len(columns))
+	used := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		name := column
+		// suffixed name is not taken if it is a name of another column of the header
+		for i := 2; used[name] || (name != column && seen[name]); i++ {
+			name = fmt.Sprintf("%s_%d", column, i)
+		}
+		used[name] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+func (x xlsxDataSource) GetData(_ context.Context) (*domain.Payload, error) {
+	for {
+		if x.rowNum.Load() >= x.lastRow || !x.rows.Next() {
+			err := x.rows.Error()
+			if err != nil {
+				return nil, errors.WithMessagef(err, "read rows of sheet '%s'", x.sheet)
+			}
+			return nil, domain.ErrNoData
+		}
+		rowNum := x.rowNum.Add(1)
+
+		row, err := x.rows.Columns()
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read row %d of sheet '%s'", rowNum, x.sheet)
+		}
+		row = x.cellRange.cells(row)
+		if isEmptyRow(row) {
+			continue
+		}
+
+		data := make(map[string]any, len(x.columns))
+		for i, column := range x.columns {
+			value := ""
+			if i < len(row) {
+				value = row[i]
+			}
+			data[column] = value
+		}
+		x.readCounter.Add(1)
+
+		metadata := domain.NewMetadata()
+		metadata.SourceFile = x.filePath
+		metadata.SourceRow = rowNum
+		return &domain.Payload{
+			Data:     data,
+			Metadata: metadata,
+			Position: domain.RowNumPosition{WorkerIdx: 0, RowNum: rowNum},
+		}, nil
+	}
+}
+
+//nolint:mnd
+func (x xlsxDataSource) Progress() domain.Progress {
+	readDataPercent := float64(100)
+	if total := x.lastRow - x.headerRow; total > 0 {
+		readDataPercent = float64(x.rowNum.Load()-x.headerRow) / float64(total) * 100
+	}
+	return domain.Progress{
+		ReadDataCount:   x.readCounter.Load(),
+		ReadDataPercent: &readDataPercent,
+	}
+}
+
+func (x xlsxDataSource) Close(_ context.Context) error {
+	if x.rows != nil {
+		err := x.rows.Close()
+		if err != nil {
+			return errors.WithMessage(err, "close sheet rows")
+		}
+	}
+	err := x.workbook.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close xlsx workbook")
+	}
+	return nil
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// cellRange is a rectangle of cells with 1-based coordinates, zero upper bound means no bound.
+type cellRange struct {
+	fromCol int
+	fromRow int64
+	toCol   int
+	toRow   int64
+}
+
+// parseCellRange parses range like 'B2:F100', empty range means the whole sheet.
+func parseCellRange(ref string) (cellRange, error) {
+	if ref == "" {
+		return cellRange{fromCol: 1, fromRow: 1}, nil
+	}
+
+	from, to, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(ref)), ":")
+	if !ok {
+		return cellRange{}, errors.New("expected range in 'A1:D100' format")
+	}
+	fromCol, fromRow, err := excelize.CellNameToCoordinates(from)
+	if err != nil {
+		return cellRange{}, errors.WithMessage(err, "parse first cell")
+	}
+	toCol, toRow, err := excelize.CellNameToCoordinates(to)
+	if err != nil {
+		return cellRange{}, errors.WithMessage(err, "parse last cell")
+	}
+	if fromCol > toCol || fromRow > toRow {
+		return cellRange{}, errors.New("first cell must be above and to the left of last cell")
+	}
+	return cellRange{
+		fromCol: fromCol,
+		fromRow: int64(fromRow),
+		toCol:   toCol,
+		toRow:   int64(toRow),
+	}, nil
+}
+
+// cells returns cells of row within range columns.
+func (r cellRange) cells(row []string) []string {
+	if r.fromCol > len(row) {
+		return nil
+	}
+	row = row[r.fromCol-1:]
+	if r.toCol > 0 {
+		row = row[:min(len(row), r.toCol-r.fromCol+1)]
+	}
+	return row
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/metrics"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+type Server struct {
+	srv    *http.Server
+	logger log.Logger
+}
+
+func NewServer(address string, reg *metrics.Registry, logger log.Logger) Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg.MetricsHandler())
+	mux.Handle("/metrics/descriptions", reg.MetricsDescriptionHandler())
+	return Server{
+		srv: &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+		logger: logger,
+	}
+}
+
+func (s Server) Start(ctx context.Context) {
+	go func() {
+		s.logger.Info(ctx, "metrics server is listening", log.String("address", s.srv.Addr))
+		err := s.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error(ctx, errors.WithMessage(err, "listen and serve metrics server"))
+		}
+	}()
+}
+
+func (s Server) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "shutdown metrics server")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/txix-open/isp-kit/metrics"
+)
+
+const (
+	namespace = "mqpusher"
+)
+
+const (
+	readRecords      = "read"
+	convertedRecords = "converted"
+	filteredRecords  = "filtered"
+	publishedRecords = "published"
+	failedRecords    = "failed"
+)
+
+type Storage struct {
+	records         *prometheus.CounterVec
+	failedRecords   *prometheus.CounterVec
+	publishDuration prometheus.Histogram
+	publishRetries  prometheus.Counter
+	scriptDuration  prometheus.Histogram
+	rateLimiterWait prometheus.Histogram
+	readPercent     *atomic.Pointer[func() *float64]
+}
+
+func NewStorage(reg *metrics.Registry) *Storage {
+	s := &Storage{
+		records: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "records_total",
+			Help:      "The count of records passed through publish pipeline stages",
+		}, []string{"stage"})),
+		failedRecords: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_records_total",
+			Help:      "The count of records failed on publish pipeline stages",
+		}, []string{"stage"})),
+		publishDuration: metrics.GetOrRegister(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "The latency of publishing single record to target including retries",
+			Buckets:   prometheus.DefBuckets,
+		})),
+		publishRetries: metrics.GetOrRegister(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_retries_total",
+			Help:      "The count of publish retries",
+		})),
+		scriptDuration: metrics.GetOrRegister(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "script_duration_seconds",
+			Help:      "The latency of converting single record with script",
+			Buckets:   prometheus.DefBuckets,
+		})),
+		rateLimiterWait: metrics.GetOrRegister(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limiter_wait_seconds",
+			Help:      "The time spent waiting for rate limiter before publishing",
+			Buckets:   prometheus.DefBuckets,
+		})),
+		readPercent: new(atomic.Pointer[func() *float64]),
+	}
+	metrics.GetOrRegister(reg, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "source_read_percent",
+		Help:      "The percent of data read from source, -1 if unknown",
+	}, s.loadReadPercent))
+	return s
+}
+
+func (s *Storage) IncRead() {
+	s.records.WithLabelValues(readRecords).Inc()
+}
+
+func (s *Storage) IncConverted() {
+	s.records.WithLabelValues(convertedRecords).Inc()
+}
+
+func (s *Storage) IncFiltered() {
+	s.records.WithLabelValues(filteredRecords).Inc()
+}
+
+func (s *Storage) IncPublished() {
+	s.records.WithLabelValues(publishedRecords).Inc()
+}
+
+func (s *Storage) IncFailed(stage string) {
+	s.records.WithLabelValues(failedRecords).Inc()
+	s.failedRecords.WithLabelValues(stage).Inc()
+}
+
+func (s *Storage) ObservePublishDuration(duration time.Duration) {
+	s.publishDuration.Observe(duration.Seconds())
+}
+
+func (s *Storage) IncPublishRetry() {
+	s.publishRetries.Inc()
+}
+
+func (s *Storage) ObserveScriptDuration(duration time.Duration) {
+	s.scriptDuration.Observe(duration.Seconds())
+}
+
+func (s *Storage) ObserveRateLimiterWait(duration time.Duration) {
+	s.rateLimiterWait.Observe(duration.Seconds())
+}
+
+func (s *Storage) SetReadPercent(readPercent func() *float64) {
+	s.readPercent.Store(&readPercent)
+}
+
+func (s *Storage) loadReadPercent() float64 {
+	readPercent := s.readPercent.Load()
+	if readPercent == nil {
+		return -1
+	}
+	percent := (*readPercent)()
+	if percent == nil {
+		return -1
+	}
+	return *percent
+}
@@ -0,0 +1,130 @@
+package target
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/mqpusher/domain"
+	"github.com/txix-open/mqpusher/rmq"
+)
+
+const (
+	Stdout = "-"
+
+	EnvelopeFormat = "envelope"
+	BodyFormat     = "body"
+)
+
+type envelopeBuilder interface {
+	Build(data any, metadata *domain.Metadata) (rmq.Envelope, error)
+}
+
+// fileTarget writes each message on a separate line in one of formats:
+//   - EnvelopeFormat: envelope with resolved exchange, routing key, headers and properties,
+//     so the result can be read back with json data source and published with isEnvelope;
+//   - BodyFormat: message body only, so the result can be read back with json data source as is.
+type fileTarget struct {
+	file            io.WriteCloser
+	writer          *bufio.Writer
+	lock            *sync.Mutex
+	envelopeBuilder envelopeBuilder
+	format          string
+}
+
+// NewFile creates target writing messages to file by path or to stdout if path is Stdout.
+// Empty format means EnvelopeFormat.
+func NewFile(path string, format string, envelopeBuilder envelopeBuilder) (fileTarget, error) {
+	var file io.WriteCloser = nopCloser{Writer: os.Stdout}
+	if path != Stdout {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644) // nolint:mnd,gosec
+		if err != nil {
+			return fileTarget{}, errors.WithMessagef(err, "open file '%s'", path)
+		}
+		file = f
+	}
+
+	return fileTarget{
+		file:            file,
+		writer:          bufio.NewWriter(file),
+		lock:            new(sync.Mutex),
+		envelopeBuilder: envelopeBuilder,
+		format:          format,
+	}, nil
+}
+
+func (f fileTarget) Publish(_ context.Context, data any, metadata *domain.Metadata) error {
+	envelope, err := f.envelopeBuilder.Build(data, metadata)
+	if err != nil {
+		return errors.WithMessage(err, "build envelope")
+	}
+	line, err := f.line(envelope)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, err = f.writer.Write(line)
+	if err != nil {
+		return errors.WithMessage(err, "write data")
+	}
+	err = f.writer.WriteByte('\n')
+	if err != nil {
+		return errors.WithMessage(err, "write new line")
+	}
+	return nil
+}
+
+func (f fileTarget) line(envelope rmq.Envelope) ([]byte, error) {
+	if f.format != BodyFormat {
+		line, err := json.Marshal(envelope)
+		if err != nil {
+			return nil, errors.WithMessage(err, "marshal envelope")
+		}
+		return line, nil
+	}
+
+	if stdjson.Valid(envelope.RawBody) {
+		buf := new(bytes.Buffer)
+		err := stdjson.Compact(buf, envelope.RawBody)
+		if err != nil {
+			return nil, errors.WithMessage(err, "compact body")
+		}
+		return buf.Bytes(), nil
+	}
+	if bytes.ContainsAny(envelope.RawBody, "\r\n") {
+		return nil, errors.New("body which is not valid JSON contains line break, use envelope format")
+	}
+	return envelope.RawBody, nil
+}
+
+func (f fileTarget) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	err := f.writer.Flush()
+	if err != nil {
+		return errors.WithMessage(err, "flush data")
+	}
+	err = f.file.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close file")
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
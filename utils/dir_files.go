@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	NameSortOrder    = "name"
+	MtimeSortOrder   = "mtime"
+	NaturalSortOrder = "natural"
+)
+
+// DefaultExclude is used when ListOptions.Exclude is not set: hidden and temporary files are skipped.
+var DefaultExclude = []string{".*", "*.tmp"}
+
+type DirFile struct {
+	Path    string
+	RelPath string
+	Size    int64
+	ModTime time.Time
+}
+
+type ListOptions struct {
+	IsRecursive bool
+	// Include and Exclude are glob patterns matched against file name
+	// or, if pattern contains '/', against slash-separated path relative to directory.
+	// Nil Exclude means DefaultExclude.
+	Include   []string
+	Exclude   []string
+	SortOrder string
+}
+
+// ListDirFiles returns regular files of directory filtered and sorted by options.
+func ListDirFiles(dirPath string, opts ListOptions) ([]DirFile, error) {
+	exclude := opts.Exclude
+	if exclude == nil {
+		exclude = DefaultExclude
+	}
+	files := make([]DirFile, 0)
+	err := filepath.WalkDir(dirPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != dirPath && !opts.IsRecursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return errors.WithMessagef(err, "relative path of '%s'", filePath)
+		}
+		relPath = filepath.ToSlash(relPath)
+		isIncluded, err := isFileIncluded(relPath, opts.Include, exclude)
+		if err != nil {
+			return err
+		}
+		if !isIncluded {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return errors.WithMessagef(err, "file '%s' info", filePath)
+		}
+		files = append(files, DirFile{
+			Path:    filePath,
+			RelPath: relPath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "walk dir '%s'", dirPath)
+	}
+
+	sortFiles(files, opts.SortOrder)
+	return files, nil
+}
+
+// ListGlobFiles returns regular files matched by glob pattern sorted by sortOrder.
+func ListGlobFiles(pattern string, sortOrder string) ([]DirFile, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "glob '%s'", pattern)
+	}
+
+	files := make([]DirFile, 0, len(paths))
+	for _, filePath := range paths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "file '%s' stat", filePath)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, DirFile{
+			Path:    filePath,
+			RelPath: filepath.ToSlash(filePath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sortFiles(files, sortOrder)
+	return files, nil
+}
+
+// IsGlob reports whether path contains glob meta characters.
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func isFileIncluded(relPath string, include []string, exclude []string) (bool, error) {
+	if len(include) > 0 {
+		isMatched, err := matchAny(relPath, include)
+		if err != nil || !isMatched {
+			return false, err
+		}
+	}
+	isMatched, err := matchAny(relPath, exclude)
+	if err != nil {
+		return false, err
+	}
+	return !isMatched, nil
+}
+
+func matchAny(relPath string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relPath)
+		}
+		isMatched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, errors.WithMessagef(err, "match pattern '%s'", pattern)
+		}
+		if isMatched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sortFiles(files []DirFile, sortOrder string) {
+	switch sortOrder {
+	case MtimeSortOrder:
+		slices.SortStableFunc(files, func(a, b DirFile) int {
+			cmp := a.ModTime.Compare(b.ModTime)
+			if cmp != 0 {
+				return cmp
+			}
+			return strings.Compare(a.RelPath, b.RelPath)
+		})
+	case NaturalSortOrder:
+		slices.SortStableFunc(files, func(a, b DirFile) int {
+			return naturalCompare(a.RelPath, b.RelPath)
+		})
+	default:
+		slices.SortStableFunc(files, func(a, b DirFile) int {
+			return strings.Compare(a.RelPath, b.RelPath)
+		})
+	}
+}
+
+// naturalCompare compares strings treating digit sequences as numbers, so "2" < "10".
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			cmp := compareNumbers(aDigits, bDigits)
+			if cmp != 0 {
+				return cmp
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+		if a[0] != b[0] {
+			return strings.Compare(a[:1], b[:1])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return strings.Compare(a, b)
+}
+
+func leadingDigits(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
+
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "file2", b: "file10", expected: -1},
+		{a: "file10", b: "file2", expected: 1},
+		{a: "file2", b: "file2", expected: 0},
+		{a: "file02", b: "file2", expected: 0},
+		{a: "file2", b: "file2a", expected: -1},
+		{a: "a10b2", b: "a10b10", expected: -1},
+		{a: "a", b: "b", expected: -1},
+		{a: "2024-1/part9", b: "2024-1/part10", expected: -1},
+		{a: "99999999999999999999", b: "100000000000000000000", expected: -1},
+		{a: "", b: "1", expected: -1},
+	}
+	for _, test := range tests {
+		t.Run(test.a+"_"+test.b, func(t *testing.T) {
+			t.Parallel()
+
+			result := sign(naturalCompare(test.a, test.b))
+			if result != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		sortOrder string
+		paths     []string
+		expected  []string
+	}{
+		{
+			name:      "name",
+			sortOrder: NameSortOrder,
+			paths:     []string{"file10.json", "file2.json", "file1.json"},
+			expected:  []string{"file1.json", "file10.json", "file2.json"},
+		},
+		{
+			name:      "natural",
+			sortOrder: NaturalSortOrder,
+			paths:     []string{"file10.json", "file2.json", "file1.json"},
+			expected:  []string{"file1.json", "file2.json", "file10.json"},
+		},
+		{
+			name:      "natural with directories",
+			sortOrder: NaturalSortOrder,
+			paths:     []string{"dir10/a.json", "dir2/b.json", "dir2/a.json"},
+			expected:  []string{"dir2/a.json", "dir2/b.json", "dir10/a.json"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			files := make([]DirFile, 0, len(test.paths))
+			for _, path := range test.paths {
+				files = append(files, DirFile{RelPath: path})
+			}
+			sortFiles(files, test.sortOrder)
+			result := make([]string, 0, len(files))
+			for _, file := range files {
+				result = append(result, file.RelPath)
+			}
+			if !slices.Equal(result, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestListDirFilesDefaultExclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.json", ".hidden.json", "b.json.tmp"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		exclude  []string
+		expected []string
+	}{
+		{name: "default", exclude: nil, expected: []string{"a.json"}},
+		{name: "explicit", exclude: []string{"a.*"}, expected: []string{".hidden.json", "b.json.tmp"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			files, err := ListDirFiles(dir, ListOptions{Exclude: test.exclude})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := make([]string, 0, len(files))
+			for _, file := range files {
+				result = append(result, file.RelPath)
+			}
+			if !slices.Equal(result, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	default:
+		return 0
+	}
+}
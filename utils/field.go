@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LookupField returns non-null value of data object by dot-separated path, e.g. 'meta.queue'.
+func LookupField(data any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	v := data
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		v, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// FieldString formats field value, numbers are formatted without exponent.
+func FieldString(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
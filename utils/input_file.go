@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+)
+
+type compression string
+
+const (
+	noCompression    compression = ""
+	gzipCompression  compression = "gzip"
+	zstdCompression  compression = "zstd"
+	bzip2Compression compression = "bzip2"
+	xzCompression    compression = "xz"
+)
+
+const (
+	// StdinPath is a file path meaning standard input.
+	StdinPath = "-"
+
+	maxMagicLen = 6
+)
+
+var (
+	compressionByExt = map[string]compression{
+		".gz":   gzipCompression,
+		".gzip": gzipCompression,
+		".zst":  zstdCompression,
+		".zstd": zstdCompression,
+		".bz2":  bzip2Compression,
+		".xz":   xzCompression,
+	}
+	compressionMagics = []struct {
+		compression compression
+		magic       []byte
+	}{
+		{compression: gzipCompression, magic: []byte{0x1f, 0x8b}},
+		{compression: zstdCompression, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		{compression: bzip2Compression, magic: []byte("BZh")},
+		{compression: xzCompression, magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	}
+)
+
+// InputFile reads data file decompressing it on the fly
+// if it is compressed with gzip, zstd, bzip2 or xz.
+type InputFile struct {
+	io.Reader
+
+	file          *os.File
+	readerCounter ReaderCounter
+	closeReader   func() error
+	fileSize      float64
+	startOffset   int64
+	isStdin       bool
+}
+
+// OpenInputFile opens file and skips offset bytes of its decompressed data.
+// If path is StdinPath, standard input is read and offset is not supported.
+// Compression is detected by file extension or by magic bytes.
+func OpenInputFile(path string, offset int64) (InputFile, error) {
+	if path == StdinPath {
+		if offset > 0 {
+			return InputFile{}, errors.New("offset is not supported for stdin")
+		}
+		inputFile, err := newStdinInputFile()
+		if err != nil {
+			return InputFile{}, errors.WithMessage(err, "open stdin")
+		}
+		return inputFile, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return InputFile{}, errors.WithMessagef(err, "open file '%s'", path)
+	}
+	inputFile, err := newInputFile(file, path, offset)
+	if err != nil {
+		_ = file.Close()
+		return InputFile{}, err
+	}
+	return inputFile, nil
+}
+
+func newInputFile(file *os.File, path string, offset int64) (InputFile, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return InputFile{}, errors.WithMessage(err, "file stat")
+	}
+	compression, err := detectCompression(file, path)
+	if err != nil {
+		return InputFile{}, errors.WithMessage(err, "detect compression")
+	}
+
+	if compression == noCompression {
+		if offset > 0 {
+			_, err = file.Seek(offset, io.SeekStart)
+			if err != nil {
+				return InputFile{}, errors.WithMessagef(err, "seek file to offset %d", offset)
+			}
+		}
+		readerCounter := NewReaderCounter(file)
+		return InputFile{
+			Reader:        readerCounter,
+			file:          file,
+			readerCounter: readerCounter,
+			closeReader:   func() error { return nil },
+			fileSize:      float64(info.Size()),
+			startOffset:   offset,
+		}, nil
+	}
+
+	readerCounter := NewReaderCounter(file)
+	reader, closeReader, err := newDecompressor(compression, bufio.NewReader(readerCounter))
+	if err != nil {
+		return InputFile{}, errors.WithMessagef(err, "new %s reader", compression)
+	}
+	if offset > 0 {
+		_, err = io.CopyN(io.Discard, reader, offset)
+		if err != nil {
+			_ = closeReader()
+			return InputFile{}, errors.WithMessagef(err, "skip %d bytes of decompressed data", offset)
+		}
+	}
+
+	return InputFile{
+		Reader:        reader,
+		file:          file,
+		readerCounter: readerCounter,
+		closeReader:   closeReader,
+		fileSize:      float64(info.Size()),
+		startOffset:   0,
+	}, nil
+}
+
+func newStdinInputFile() (InputFile, error) {
+	readerCounter := NewReaderCounter(os.Stdin)
+	bufReader := bufio.NewReader(readerCounter)
+	magic, err := bufReader.Peek(maxMagicLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return InputFile{}, errors.WithMessage(err, "read magic bytes")
+	}
+
+	var (
+		compression           = compressionByMagic(magic)
+		reader      io.Reader = bufReader
+		closeReader           = func() error { return nil }
+	)
+	if compression != noCompression {
+		reader, closeReader, err = newDecompressor(compression, bufReader)
+		if err != nil {
+			return InputFile{}, errors.WithMessagef(err, "new %s reader", compression)
+		}
+	}
+
+	return InputFile{
+		Reader:        reader,
+		file:          os.Stdin,
+		readerCounter: readerCounter,
+		closeReader:   closeReader,
+		fileSize:      0,
+		startOffset:   0,
+		isStdin:       true,
+	}, nil
+}
+
+func detectCompression(file *os.File, path string) (compression, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	compression, ok := compressionByExt[ext]
+	if ok {
+		return compression, nil
+	}
+
+	magic := make([]byte, maxMagicLen)
+	n, err := file.ReadAt(magic, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return noCompression, errors.WithMessage(err, "read magic bytes")
+	}
+	return compressionByMagic(magic[:n]), nil
+}
+
+func compressionByMagic(magic []byte) compression {
+	for _, c := range compressionMagics {
+		if bytes.HasPrefix(magic, c.magic) {
+			return c.compression
+		}
+	}
+	return noCompression
+}
+
+func newDecompressor(compression compression, r io.Reader) (io.Reader, func() error, error) {
+	noClose := func() error { return nil }
+	switch compression {
+	case gzipCompression:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err // nolint:wrapcheck
+		}
+		return reader, reader.Close, nil
+	case zstdCompression:
+		reader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err // nolint:wrapcheck
+		}
+		return reader, func() error { reader.Close(); return nil }, nil
+	case bzip2Compression:
+		return bzip2.NewReader(r), noClose, nil
+	case xzCompression:
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, nil, err // nolint:wrapcheck
+		}
+		return reader, noClose, nil
+	default:
+		return nil, nil, errors.Errorf("unsupported compression '%s'", compression)
+	}
+}
+
+// ReadPercent returns percent of file read so far or nil if it is unknown for standard input.
+// For compressed file it is counted against compressed bytes.
+//
+//nolint:mnd
+func (f InputFile) ReadPercent() *float64 {
+	if f.isStdin {
+		return nil
+	}
+	readPercent := float64(100)
+	if f.fileSize > 0 {
+		readPercent = f.ReadSize() / f.fileSize * 100
+	}
+	return &readPercent
+}
+
+// ReadSize returns count of file bytes read so far including skipped ones.
+// For compressed file it is counted against compressed bytes.
+func (f InputFile) ReadSize() float64 {
+	return float64(f.startOffset) + float64(f.readerCounter.Count())
+}
+
+func (f InputFile) Close() error {
+	err := f.closeReader()
+	if err != nil {
+		_ = f.file.Close()
+		return errors.WithMessage(err, "close decompressor")
+	}
+	err = f.file.Close()
+	if err != nil {
+		return errors.WithMessage(err, "close file")
+	}
+	return nil
+}
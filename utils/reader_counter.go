@@ -26,10 +26,13 @@ func NewReaderCounter(r baseReader) ReaderCounter {
 
 func (r ReaderCounter) Read(buf []byte) (int, error) {
 	n, err := r.baseReader.Read(buf)
+	r.count.Add(uint64(n)) // nolint:gosec
+	if errors.Is(err, io.EOF) {
+		return n, io.EOF
+	}
 	if err != nil {
-		return 0, errors.WithMessage(err, "base reader read")
+		return n, errors.WithMessage(err, "base reader read")
 	}
-	r.count.Add(uint64(n)) // nolint:gosec
 	return n, nil
 }
 